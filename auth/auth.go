@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+
+	http_header "github.com/bborbe/http/header"
+	"github.com/bborbe/teamvault-utils"
+	"github.com/golang/glog"
+)
+
+// Authenticator returns the value of the Authorization header sent to Teamvault.
+type Authenticator interface {
+	Authorization(ctx context.Context) (string, error)
+}
+
+// Credentials returns user and password for basic authentication.
+type Credentials func(ctx context.Context) (teamvault.User, teamvault.Password, error)
+
+type bearer struct {
+	token teamvault.Token
+}
+
+// NewBearer authenticates with an api token.
+func NewBearer(token teamvault.Token) Authenticator {
+	return &bearer{token: token}
+}
+
+func (b *bearer) Authorization(ctx context.Context) (string, error) {
+	return fmt.Sprintf("Bearer %s", b.token.String()), nil
+}
+
+type basic struct {
+	credentials Credentials
+	mux         sync.Mutex
+	header      string
+}
+
+// NewBasic authenticates with user and password. The credentials are asked
+// for once, on the first request.
+func NewBasic(credentials Credentials) Authenticator {
+	return &basic{credentials: credentials}
+}
+
+func (b *basic) Authorization(ctx context.Context) (string, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.header == "" {
+		user, pass, err := b.credentials(ctx)
+		if err != nil {
+			return "", err
+		}
+		b.header = fmt.Sprintf("Basic %s", http_header.CreateAuthorizationToken(user.String(), pass.String()))
+	}
+	return b.header, nil
+}
+
+// Static returns the given user and password.
+func Static(user teamvault.User, pass teamvault.Password) Credentials {
+	return func(ctx context.Context) (teamvault.User, teamvault.Password, error) {
+		return user, pass, nil
+	}
+}
+
+// New picks the authentication configured: token, password, pass_command,
+// a netrc entry for the Teamvault host and at last a prompt on the terminal.
+func New(config *teamvault.TeamvaultConfig) Authenticator {
+	switch {
+	case config.Token != "":
+		glog.V(2).Infof("authenticate with token")
+		return NewBearer(config.Token)
+	case config.Password != "":
+		glog.V(2).Infof("authenticate with password")
+		return NewBasic(Static(config.User, config.Password))
+	case config.PassCommand != "":
+		glog.V(2).Infof("authenticate with pass_command")
+		return NewBasic(Command(config.User, config.PassCommand))
+	}
+	netrc := config.Netrc
+	if netrc == "" {
+		netrc = DefaultNetrc
+	}
+	return NewBasic(func(ctx context.Context) (teamvault.User, teamvault.Password, error) {
+		user, pass, err := Netrc(netrc, host(config.Url), config.User)(ctx)
+		if err == nil {
+			glog.V(2).Infof("authenticate with netrc %s", netrc)
+			return user, pass, nil
+		}
+		glog.V(2).Infof("read netrc %s failed: %v", netrc, err)
+		return Prompt(config.Url, config.User)(ctx)
+	})
+}
+
+func host(teamvaultUrl teamvault.Url) string {
+	u, err := url.Parse(teamvaultUrl.String())
+	if err != nil {
+		return teamvaultUrl.String()
+	}
+	return u.Hostname()
+}
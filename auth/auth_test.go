@@ -0,0 +1,166 @@
+package auth_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/bborbe/assert"
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/auth"
+)
+
+func TestBearer(t *testing.T) {
+	authorization, err := auth.NewBearer("T0K3N").Authorization(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(authorization, Is("Bearer T0K3N")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBasicAsksOnce(t *testing.T) {
+	counter := 0
+	a := auth.NewBasic(func(ctx context.Context) (teamvault.User, teamvault.Password, error) {
+		counter++
+		return "user", "pass", nil
+	})
+	for i := 0; i < 2; i++ {
+		authorization, err := a.Authorization(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := AssertThat(authorization, Is("Basic dXNlcjpwYXNz")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := AssertThat(counter, Is(1)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	user, pass, err := auth.Command("user", "echo S3CR3T")(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(user.String(), Is("user")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(pass.String(), Is("S3CR3T")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommandFails(t *testing.T) {
+	_, _, err := auth.Command("user", "exit 1")(context.Background())
+	if err := AssertThat(err, NotNilValue()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeNetrc(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "netrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "netrc")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNetrc(t *testing.T) {
+	path := writeNetrc(t, `machine other.example.com login other password wrong
+machine teamvault.example.com
+	login user
+	password S3CR3T
+default login anonymous password guest
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+	user, pass, err := auth.Netrc(path, "teamvault.example.com", "")(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(user.String(), Is("user")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(pass.String(), Is("S3CR3T")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNetrcDefault(t *testing.T) {
+	path := writeNetrc(t, `machine other.example.com login other password wrong
+macdef init
+machine teamvault.example.com login macro password macro
+
+default login anonymous password guest
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+	user, pass, err := auth.Netrc(path, "teamvault.example.com", "configured")(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(user.String(), Is("configured")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(pass.String(), Is("guest")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNetrcMissingEntry(t *testing.T) {
+	path := writeNetrc(t, `machine other.example.com login other password wrong`)
+	defer os.RemoveAll(filepath.Dir(path))
+	_, _, err := auth.Netrc(path, "teamvault.example.com", "")(context.Background())
+	if err := AssertThat(err, NotNilValue()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewPrefersToken(t *testing.T) {
+	authorization, err := auth.New(&teamvault.TeamvaultConfig{
+		User:     "user",
+		Password: "pass",
+		Token:    "T0K3N",
+	}).Authorization(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(authorization, Is("Bearer T0K3N")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewPassCommand(t *testing.T) {
+	authorization, err := auth.New(&teamvault.TeamvaultConfig{
+		User:        "user",
+		PassCommand: "printf pass",
+	}).Authorization(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(authorization, Is("Basic dXNlcjpwYXNz")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewNetrc(t *testing.T) {
+	path := writeNetrc(t, `machine teamvault.example.com login user password pass`)
+	defer os.RemoveAll(filepath.Dir(path))
+	authorization, err := auth.New(&teamvault.TeamvaultConfig{
+		Url:   "https://teamvault.example.com",
+		Netrc: path,
+	}).Authorization(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(authorization, Is("Basic dXNlcjpwYXNz")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/bborbe/teamvault-utils"
+)
+
+// Command runs the shell command and uses its output as password,
+// e.g. "pass show teamvault".
+func Command(user teamvault.User, command string) Credentials {
+	return func(ctx context.Context) (teamvault.User, teamvault.Password, error) {
+		var stdout bytes.Buffer
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Stdout = &stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() != nil {
+				return "", "", ctx.Err()
+			}
+			return "", "", fmt.Errorf("run pass_command failed: %v", err)
+		}
+		pass := strings.TrimRight(stdout.String(), "\r\n")
+		if pass == "" {
+			return "", "", fmt.Errorf("pass_command printed no password")
+		}
+		return user, teamvault.Password(pass), nil
+	}
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	io_util "github.com/bborbe/io/util"
+	"github.com/bborbe/teamvault-utils"
+)
+
+const DefaultNetrc = "~/.netrc"
+
+// Netrc reads login and password of the machine from the netrc file. A
+// configured user takes precedence over the login of the entry.
+func Netrc(path string, machine string, user teamvault.User) Credentials {
+	return func(ctx context.Context) (teamvault.User, teamvault.Password, error) {
+		path, err := io_util.NormalizePath(path)
+		if err != nil {
+			return "", "", err
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", "", err
+		}
+		login, pass, ok := parseNetrc(string(content), machine)
+		if !ok {
+			return "", "", fmt.Errorf("no entry for machine %s in %s", machine, path)
+		}
+		if user == "" {
+			user = teamvault.User(login)
+		}
+		return user, teamvault.Password(pass), nil
+	}
+}
+
+type netrcEntry struct {
+	login    string
+	password string
+}
+
+// parseNetrc returns login and password of the machine or of the default entry.
+func parseNetrc(content string, machine string) (string, string, bool) {
+	var current, found, fallback *netrcEntry
+	var fields []string
+	inMacro := false
+	for _, line := range strings.Split(content, "\n") {
+		if inMacro {
+			// a macro definition ends with an empty line
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		lineFields := strings.Fields(line)
+		for i, field := range lineFields {
+			if field == "macdef" && (i == 0 || lineFields[i-1] != "password") {
+				lineFields = lineFields[:i]
+				inMacro = true
+				break
+			}
+		}
+		fields = append(fields, lineFields...)
+	}
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			current = &netrcEntry{}
+			if i+1 < len(fields) {
+				i++
+				if fields[i] == machine && found == nil {
+					found = current
+				}
+			}
+		case "default":
+			current = &netrcEntry{}
+			if fallback == nil {
+				fallback = current
+			}
+		case "login", "password", "account":
+			if i+1 >= len(fields) || current == nil {
+				continue
+			}
+			i++
+			switch fields[i-1] {
+			case "login":
+				current.login = fields[i]
+			case "password":
+				current.password = fields[i]
+			}
+		}
+	}
+	for _, e := range []*netrcEntry{found, fallback} {
+		if e != nil && e.password != "" {
+			return e.login, e.password, true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/bborbe/teamvault-utils"
+)
+
+// Prompt asks for the password, and the user if not configured, on the
+// controlling terminal. The password is read without echo. Stdin stays
+// untouched, so it works for commands reading a template from stdin.
+func Prompt(teamvaultUrl teamvault.Url, user teamvault.User) Credentials {
+	return func(ctx context.Context) (teamvault.User, teamvault.Password, error) {
+		tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			return "", "", fmt.Errorf("no credentials configured and no terminal to ask for them: %v", err)
+		}
+		defer tty.Close()
+		reader := bufio.NewReader(tty)
+		if user == "" {
+			fmt.Fprintf(tty, "Teamvault user for %s: ", teamvaultUrl)
+			line, err := readLine(ctx, reader)
+			if err != nil {
+				return "", "", err
+			}
+			user = teamvault.User(line)
+		}
+		if err := stty(tty, "-echo"); err != nil {
+			return "", "", fmt.Errorf("disable echo failed: %v", err)
+		}
+		defer fmt.Fprintln(tty)
+		defer stty(tty, "echo")
+		fmt.Fprintf(tty, "Teamvault password for %s@%s: ", user, teamvaultUrl)
+		line, err := readLine(ctx, reader)
+		if err != nil {
+			return "", "", err
+		}
+		return user, teamvault.Password(line), nil
+	}
+}
+
+func stty(tty *os.File, arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = tty
+	return cmd.Run()
+}
+
+// readLine returns the next line or the error of the context if it ends first.
+func readLine(ctx context.Context, reader *bufio.Reader) (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		line, err := reader.ReadString('\n')
+		ch <- result{line: strings.TrimRight(line, "\r\n"), err: err}
+	}()
+	select {
+	case r := <-ch:
+		if r.err != nil && r.line == "" {
+			return "", fmt.Errorf("read from terminal failed: %v", r.err)
+		}
+		return r.line, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
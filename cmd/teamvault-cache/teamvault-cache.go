@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/connector"
+	"github.com/bborbe/teamvault-utils/factory"
+	"github.com/bborbe/teamvault-utils/generator"
+	"github.com/bborbe/teamvault-utils/parser"
+	"github.com/golang/glog"
+)
+
+var (
+	teamvaultUrlPtr        = flag.String("teamvault-url", "", "teamvault url")
+	teamvaultUserPtr       = flag.String("teamvault-user", "", "teamvault user")
+	teamvaultPassPtr       = flag.String("teamvault-pass", "", "teamvault password")
+	teamvaultConfigPathPtr = flag.String("teamvault-config", "", "teamvault config")
+	timeoutPtr             = flag.Duration("timeout", 0, "abort after the given duration, 0 disables")
+	retriesPtr             = flag.Int("retries", -1, "number of retries for failed teamvault requests, -1 uses the config")
+	diskFallbackKeyFilePtr = flag.String("disk-fallback-key-file", "", "keyfile the entries are encrypted with")
+	teamvaultKeyPtr        = flag.String("teamvault-key", "", "key to purge")
+	allPtr                 = flag.Bool("all", false, "purge all keys")
+	maxAgePtr              = flag.Duration("max-age", 0, "prune entries fetched longer ago")
+	sourceDirectoryPtr     = flag.String("source-dir", "", "template directory to warm the cache for")
+	prefetchWorkersPtr     = flag.Int("prefetch-workers", 8, "number of concurrent requests to warm the cache")
+)
+
+const usage = "usage: teamvault-cache [flags] list|purge|prune|verify|warm|migrate"
+
+func main() {
+	defer glog.Flush()
+	glog.CopyStandardLogTo("info")
+	flag.Parse()
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := do(ctx)
+	if err != nil {
+		glog.Error(err)
+		glog.Flush()
+		os.Exit(teamvault.ExitCode(err))
+	}
+}
+
+func do(ctx context.Context) error {
+	if *timeoutPtr > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeoutPtr)
+		defer cancel()
+	}
+	teamvaultConfig := &teamvault.TeamvaultConfig{
+		Url:      teamvault.Url(*teamvaultUrlPtr),
+		User:     teamvault.User(*teamvaultUserPtr),
+		Password: teamvault.Password(*teamvaultPassPtr),
+	}
+	teamvaultConfigPath := teamvault.TeamvaultConfigPath(*teamvaultConfigPathPtr)
+	if teamvaultConfigPath.Exists() {
+		var err error
+		teamvaultConfig, err = teamvaultConfigPath.Parse()
+		if err != nil {
+			glog.V(2).Infof("parse teamvault config failed: %v", err)
+			return err
+		}
+	}
+	teamvaultFactory := factory.New(teamvaultConfig, false).
+		WithRetries(*retriesPtr).
+		WithDiskFallback(true).
+		WithDiskFallbackKeyFile(*diskFallbackKeyFilePtr)
+	defer func() {
+		if err := teamvaultFactory.Close(); err != nil {
+			glog.Warning(err)
+		}
+	}()
+	store, err := teamvaultFactory.DiskFallback()
+	if err != nil {
+		return err
+	}
+	switch flag.Arg(0) {
+	case "list":
+		return list(store)
+	case "purge":
+		return purge(store)
+	case "prune":
+		return prune(store)
+	case "verify":
+		return verify(ctx, teamvaultFactory, store)
+	case "warm":
+		return warm(ctx, teamvaultFactory, store)
+	case "migrate":
+		return migrate(store)
+	default:
+		return fmt.Errorf(usage)
+	}
+}
+
+func list(store *connector.DiskFallback) error {
+	entries, err := store.Entries()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tKIND\tAGE\tREVISION")
+	for _, entry := range entries {
+		if entry.Err != nil {
+			fmt.Fprintf(w, "%v\t%s\t-\t%v\n", entry.Key, entry.Kind, entry.Err)
+			continue
+		}
+		fmt.Fprintf(w, "%v\t%s\t%s\t%s\n", entry.Key, entry.Kind, age(entry.Fetched), entry.Revision)
+	}
+	return w.Flush()
+}
+
+func purge(store *connector.DiskFallback) error {
+	if *allPtr {
+		return store.PurgeAll()
+	}
+	if *teamvaultKeyPtr == "" {
+		return fmt.Errorf("parameter teamvault-key or all missing")
+	}
+	return store.Purge(teamvault.Key(*teamvaultKeyPtr))
+}
+
+func prune(store *connector.DiskFallback) error {
+	if *maxAgePtr <= 0 {
+		return fmt.Errorf("parameter max-age missing")
+	}
+	count, unreadable, err := store.Prune(*maxAgePtr)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d entries pruned\n", count)
+	for _, entry := range unreadable {
+		fmt.Fprintf(os.Stderr, "%v %s kept, unreadable: %v\n", entry.Key, entry.Kind, entry.Err)
+	}
+	if len(unreadable) > 0 {
+		return fmt.Errorf("%d entries are unreadable and were kept, purge them explicitly", len(unreadable))
+	}
+	return nil
+}
+
+// migrate encrypts all plaintext entries with the configured key.
+func migrate(store *connector.DiskFallback) error {
+	count, err := store.Migrate()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d entries encrypted\n", count)
+	return nil
+}
+
+// verify compares the revision of every entry with the current revision
+// in Teamvault and fails if an entry is stale or unreadable.
+func verify(ctx context.Context, teamvaultFactory *factory.Factory, store *connector.DiskFallback) error {
+	base, err := teamvaultFactory.Base()
+	if err != nil {
+		return err
+	}
+	entries, err := store.Entries()
+	if err != nil {
+		return err
+	}
+	revisions := make(map[teamvault.Key]string)
+	var failed int
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tKIND\tAGE\tSTATUS")
+	for _, entry := range entries {
+		status := "ok"
+		if entry.Err != nil {
+			status = fmt.Sprintf("unreadable: %v", entry.Err)
+		} else if _, pinned := entry.Key.Split(); pinned != "" {
+			// the data of a pinned revision never changes
+			if entry.Revision != pinned.String() {
+				status = "unknown revision"
+			}
+		} else {
+			current, ok := revisions[entry.Key]
+			if !ok {
+				secret, err := base.Secret(ctx, entry.Key)
+				if err != nil {
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					current = fmt.Sprintf("error: %v", teamvault.ErrorType(err))
+				} else {
+					current = secret.CurrentRevision.String()
+				}
+				revisions[entry.Key] = current
+			}
+			switch {
+			case entry.Revision == "":
+				status = "unknown revision"
+			case entry.Revision != current:
+				status = "stale"
+			}
+		}
+		if status != "ok" {
+			failed++
+		}
+		fmt.Fprintf(w, "%v\t%s\t%s\t%s\n", entry.Key, entry.Kind, age(entry.Fetched), status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d entries are not current", failed, len(entries))
+	}
+	return nil
+}
+
+// warm reads all values referenced by the templates through the
+// diskfallback layer and fails if one of them is not stored afterwards.
+func warm(ctx context.Context, teamvaultFactory *factory.Factory, store *connector.DiskFallback) error {
+	if *sourceDirectoryPtr == "" {
+		return fmt.Errorf("parameter source-dir missing")
+	}
+	references, err := generator.References(ctx, teamvault.SourceDirectory(*sourceDirectoryPtr))
+	if err != nil {
+		return err
+	}
+	teamvaultConnector, err := teamvaultFactory.Connector()
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	if err := parser.Prefetch(ctx, teamvaultConnector, references, *prefetchWorkersPtr); err != nil {
+		return err
+	}
+	var missing int
+	for _, reference := range references {
+		entry, err := store.Entry(reference.Key, string(reference.Kind))
+		if err != nil || entry.Fetched.Before(start) {
+			fmt.Fprintf(os.Stderr, "%s of %v not stored\n", reference.Kind, reference.Key)
+			missing++
+		}
+	}
+	fmt.Printf("%d of %d entries stored\n", len(references)-missing, len(references))
+	if missing > 0 {
+		return fmt.Errorf("%d entries missing", missing)
+	}
+	return nil
+}
+
+func age(fetched time.Time) string {
+	if fetched.IsZero() {
+		return "unknown"
+	}
+	return time.Since(fetched).Round(time.Second).String()
+}
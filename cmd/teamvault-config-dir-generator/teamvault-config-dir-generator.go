@@ -1,13 +1,15 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"os"
+	"os/signal"
 	"runtime"
-	"time"
+	"syscall"
 
-	"github.com/bborbe/http/client_builder"
 	"github.com/bborbe/teamvault-utils"
-	"github.com/bborbe/teamvault-utils/connector"
+	"github.com/bborbe/teamvault-utils/factory"
 	"github.com/bborbe/teamvault-utils/generator"
 	"github.com/bborbe/teamvault-utils/parser"
 	"github.com/golang/glog"
@@ -21,6 +23,20 @@ var (
 	sourceDirectoryPtr     = flag.String("source-dir", "", "source directory")
 	targetDirectoryPtr     = flag.String("target-dir", "", "target directory")
 	stagingPtr             = flag.Bool("staging", false, "staging status")
+	fixturePtr             = flag.String("fixture", "", "with staging read secrets from this JSON or YAML fixture file instead of dummy values")
+	timeoutPtr             = flag.Duration("timeout", 0, "abort after the given duration, 0 disables")
+	retriesPtr             = flag.Int("retries", -1, "number of retries for failed teamvault requests, -1 uses the config")
+	metricsFilePtr         = flag.String("metrics-file", "", "add the metrics layer and write the metrics in Prometheus text format to this file at exit")
+	recordPtr              = flag.String("record", "", "add the record layer and write all responses to this cassette file at exit")
+	recordFakePtr          = flag.Bool("record-fake", false, "record dummy values instead of passwords, files and credit cards")
+	replayPtr              = flag.String("replay", "", "answer from this cassette file instead of teamvault")
+	envPtr                 = flag.Bool("env", false, "add the env layer, answering passwords, users, urls and files from environment variables like TEAMVAULT_<key>_PASSWORD")
+	envPatternPtr          = flag.String("env-pattern", "", "add the env layer and name its variables with this pattern, e.g. TEAMVAULT_{key}_{KIND}")
+	envStrictPtr           = flag.Bool("env-strict", false, "add the env layer and fail for secrets not set in the environment instead of asking teamvault")
+	diskFallbackPtr        = flag.Bool("disk-fallback", false, "add the diskfallback layer, serving secrets from ~/.teamvault-cache if teamvault fails")
+	diskFallbackKeyFilePtr = flag.String("disk-fallback-key-file", "", "add the diskfallback layer and encrypt its entries with this keyfile")
+	prefetchWorkersPtr     = flag.Int("prefetch-workers", 8, "number of concurrent requests to prefetch the secrets of the templates, 0 disables")
+	accessRequestReasonPtr = flag.String("access-request-reason", "", "request access to secrets with access policy request with this reason and wait for approval")
 )
 
 func main() {
@@ -29,40 +45,64 @@ func main() {
 	flag.Parse()
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
-	err := do()
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := do(ctx)
 	if err != nil {
-		glog.Exit(err)
+		glog.Error(err)
+		glog.Flush()
+		os.Exit(teamvault.ExitCode(err))
 	}
 }
 
-func do() error {
-	teamvaultUrl := teamvault.Url(*teamvaultUrlPtr)
-	teamvaultUser := teamvault.User(*teamvaultUserPtr)
-	teamvaultPassword := teamvault.Password(*teamvaultPassPtr)
+func do(ctx context.Context) error {
+	if *timeoutPtr > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeoutPtr)
+		defer cancel()
+	}
+	teamvaultConfig := &teamvault.TeamvaultConfig{
+		Url:      teamvault.Url(*teamvaultUrlPtr),
+		User:     teamvault.User(*teamvaultUserPtr),
+		Password: teamvault.Password(*teamvaultPassPtr),
+	}
 	teamvaultConfigPath := teamvault.TeamvaultConfigPath(*teamvaultConfigPathPtr)
 	sourceDirectory := teamvault.SourceDirectory(*sourceDirectoryPtr)
 	targetDirectory := teamvault.TargetDirectory(*targetDirectoryPtr)
 	staging := teamvault.Staging(*stagingPtr)
 	if teamvaultConfigPath.Exists() {
-		teamvaultConfig, err := teamvaultConfigPath.Parse()
+		var err error
+		teamvaultConfig, err = teamvaultConfigPath.Parse()
 		if err != nil {
 			glog.V(2).Infof("parse teamvault config failed: %v", err)
 			return err
 		}
-		teamvaultUrl = teamvaultConfig.Url
-		teamvaultUser = teamvaultConfig.User
-		teamvaultPassword = teamvaultConfig.Password
 	}
-	httpClient := client_builder.New().WithTimeout(5 * time.Second).Build()
-	var teamvaultConnector teamvault.Connector
-	if !staging {
-		teamvaultConnector = connector.NewRemote(httpClient.Do, teamvaultUrl, teamvaultUser, teamvaultPassword)
-	} else {
-		teamvaultConnector = connector.NewDummy()
+	teamvaultFactory := factory.New(teamvaultConfig, staging).
+		WithRetries(*retriesPtr).
+		WithFixture(*fixturePtr).
+		WithDiskFallback(*diskFallbackPtr).
+		WithDiskFallbackKeyFile(*diskFallbackKeyFilePtr).
+		WithMetricsFile(*metricsFilePtr).
+		WithRecordFile(*recordPtr, *recordFakePtr).
+		WithReplayFile(*replayPtr).
+		WithAccessRequestReason(teamvault.Reason(*accessRequestReasonPtr)).
+		WithEnv(*envPtr).
+		WithEnvPattern(*envPatternPtr).
+		WithEnvStrict(*envStrictPtr)
+	defer func() {
+		if err := teamvaultFactory.Close(); err != nil {
+			glog.Warning(err)
+		}
+	}()
+	teamvaultConnector, err := teamvaultFactory.Connector()
+	if err != nil {
+		return err
 	}
 	configParser := parser.New(teamvaultConnector)
-	manifestsGenerator := generator.New(configParser)
-	if err := manifestsGenerator.Generate(sourceDirectory, targetDirectory); err != nil {
+	manifestsGenerator := generator.New(configParser).WithPrefetch(teamvaultConnector, *prefetchWorkersPtr)
+	if err := manifestsGenerator.Generate(ctx, sourceDirectory, targetDirectory); err != nil {
 		return err
 	}
 	return nil
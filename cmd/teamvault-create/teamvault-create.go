@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"syscall"
+
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/factory"
+	"github.com/golang/glog"
+)
+
+var (
+	teamvaultUrlPtr        = flag.String("teamvault-url", "", "teamvault url")
+	teamvaultUserPtr       = flag.String("teamvault-user", "", "teamvault user")
+	teamvaultPassPtr       = flag.String("teamvault-pass", "", "teamvault password")
+	teamvaultConfigPathPtr = flag.String("teamvault-config", "", "teamvault config")
+	stagingPtr             = flag.Bool("staging", false, "staging status")
+	timeoutPtr             = flag.Duration("timeout", 0, "abort after the given duration, 0 disables")
+	contentTypePtr         = flag.String("content-type", "password", "content type of the secret: password, file or url")
+	namePtr                = flag.String("name", "", "name of the secret")
+	descriptionPtr         = flag.String("description", "", "description of the secret")
+	usernamePtr            = flag.String("username", "", "username stored with the secret")
+	urlPtr                 = flag.String("url", "", "url stored with the secret")
+	filenamePtr            = flag.String("filename", "", "filename stored with the secret, defaults to the basename of -file")
+	accessPolicyPtr        = flag.String("access-policy", "", "access policy: request, everyone or hidden")
+	passwordFilePtr        = flag.String("password-file", "", "path of the file containing the password to store, - reads stdin")
+	filePtr                = flag.String("file", "", "path of the file to store")
+)
+
+func main() {
+	defer glog.Flush()
+	glog.CopyStandardLogTo("info")
+	flag.Parse()
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := do(ctx)
+	if err != nil {
+		glog.Error(err)
+		glog.Flush()
+		os.Exit(teamvault.ExitCode(err))
+	}
+}
+
+func do(ctx context.Context) error {
+	if *timeoutPtr > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeoutPtr)
+		defer cancel()
+	}
+	teamvaultConfig := &teamvault.TeamvaultConfig{
+		Url:      teamvault.Url(*teamvaultUrlPtr),
+		User:     teamvault.User(*teamvaultUserPtr),
+		Password: teamvault.Password(*teamvaultPassPtr),
+	}
+	teamvaultConfigPath := teamvault.TeamvaultConfigPath(*teamvaultConfigPathPtr)
+	staging := teamvault.Staging(*stagingPtr)
+	if teamvaultConfigPath.Exists() {
+		var err error
+		teamvaultConfig, err = teamvaultConfigPath.Parse()
+		if err != nil {
+			glog.V(2).Infof("parse teamvault config failed: %v", err)
+			return err
+		}
+	}
+	secret, err := newSecret()
+	if err != nil {
+		return err
+	}
+	teamvaultFactory := factory.New(teamvaultConfig, staging)
+	defer func() {
+		if err := teamvaultFactory.Close(); err != nil {
+			glog.Warning(err)
+		}
+	}()
+	teamvaultWriter, err := teamvaultFactory.Base()
+	if err != nil {
+		return err
+	}
+	key, err := teamvaultWriter.Create(ctx, *secret)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%v\n", key)
+	return nil
+}
+
+func newSecret() (*teamvault.NewSecret, error) {
+	secret := &teamvault.NewSecret{
+		ContentType:  teamvault.ContentType(*contentTypePtr),
+		Name:         teamvault.Name(*namePtr),
+		Description:  teamvault.Description(*descriptionPtr),
+		User:         teamvault.User(*usernamePtr),
+		Url:          teamvault.Url(*urlPtr),
+		Filename:     teamvault.Filename(*filenamePtr),
+		AccessPolicy: teamvault.AccessPolicy(*accessPolicyPtr),
+	}
+	// url secrets are password secrets whose main value is the url
+	if *contentTypePtr == "url" {
+		if secret.Url == "" {
+			return nil, fmt.Errorf("url missing")
+		}
+		secret.ContentType = teamvault.ContentTypePassword
+	}
+	if *passwordFilePtr != "" {
+		password, err := teamvault.ReadPasswordFile(*passwordFilePtr)
+		if err != nil {
+			return nil, err
+		}
+		secret.Password = password
+	} else if secret.ContentType == teamvault.ContentTypePassword {
+		return nil, fmt.Errorf("password missing, use -password-file, - reads stdin")
+	}
+	if *filePtr != "" {
+		content, err := ioutil.ReadFile(*filePtr)
+		if err != nil {
+			glog.V(2).Infof("read file %s failed: %v", *filePtr, err)
+			return nil, err
+		}
+		secret.File = teamvault.File(base64.StdEncoding.EncodeToString(content))
+		if secret.Filename == "" {
+			secret.Filename = teamvault.Filename(filepath.Base(*filePtr))
+		}
+	}
+	if err := secret.Validate(); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
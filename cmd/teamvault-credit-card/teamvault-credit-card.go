@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/factory"
+	"github.com/golang/glog"
+)
+
+var (
+	teamvaultUrlPtr        = flag.String("teamvault-url", "", "teamvault url")
+	teamvaultUserPtr       = flag.String("teamvault-user", "", "teamvault user")
+	teamvaultPassPtr       = flag.String("teamvault-pass", "", "teamvault password")
+	teamvaultConfigPathPtr = flag.String("teamvault-config", "", "teamvault config")
+	stagingPtr             = flag.Bool("staging", false, "staging status")
+	timeoutPtr             = flag.Duration("timeout", 0, "abort after the given duration, 0 disables")
+	retriesPtr             = flag.Int("retries", -1, "number of retries for failed teamvault requests, -1 uses the config")
+	teamvaultKeyPtr        = flag.String("teamvault-key", "", "teamvault key")
+	fieldPtr               = flag.String("field", "", "field to print: holder, number, expiration, expiration_month, expiration_year or security_code, all fields as json if empty")
+)
+
+func main() {
+	defer glog.Flush()
+	glog.CopyStandardLogTo("info")
+	flag.Parse()
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := do(ctx)
+	if err != nil {
+		glog.Error(err)
+		glog.Flush()
+		os.Exit(teamvault.ExitCode(err))
+	}
+}
+
+func do(ctx context.Context) error {
+	if *timeoutPtr > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeoutPtr)
+		defer cancel()
+	}
+	teamvaultConfig := &teamvault.TeamvaultConfig{
+		Url:      teamvault.Url(*teamvaultUrlPtr),
+		User:     teamvault.User(*teamvaultUserPtr),
+		Password: teamvault.Password(*teamvaultPassPtr),
+	}
+	teamvaultConfigPath := teamvault.TeamvaultConfigPath(*teamvaultConfigPathPtr)
+	staging := teamvault.Staging(*stagingPtr)
+	if teamvaultConfigPath.Exists() {
+		var err error
+		teamvaultConfig, err = teamvaultConfigPath.Parse()
+		if err != nil {
+			glog.V(2).Infof("parse teamvault config failed: %v", err)
+			return err
+		}
+	}
+	teamvaultFactory := factory.New(teamvaultConfig, staging).WithRetries(*retriesPtr)
+	defer func() {
+		if err := teamvaultFactory.Close(); err != nil {
+			glog.Warning(err)
+		}
+	}()
+	teamvaultConnector, err := teamvaultFactory.Connector()
+	if err != nil {
+		return err
+	}
+	creditCard, err := teamvaultConnector.CreditCard(ctx, teamvault.Key(*teamvaultKeyPtr))
+	if err != nil {
+		return err
+	}
+	if *fieldPtr == "" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(creditCard)
+	}
+	result, err := creditCard.Field(*fieldPtr)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%v\n", result)
+	return nil
+}
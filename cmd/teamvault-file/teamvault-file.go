@@ -1,14 +1,16 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"os"
+	"os/signal"
 	"runtime"
-	"time"
+	"syscall"
 
-	"github.com/bborbe/http/client_builder"
 	"github.com/bborbe/teamvault-utils"
-	"github.com/bborbe/teamvault-utils/connector"
+	"github.com/bborbe/teamvault-utils/factory"
 	"github.com/golang/glog"
 )
 
@@ -18,6 +20,8 @@ var (
 	teamvaultPassPtr       = flag.String("teamvault-pass", "", "teamvault password")
 	teamvaultConfigPathPtr = flag.String("teamvault-config", "", "teamvault config")
 	stagingPtr             = flag.Bool("staging", false, "staging status")
+	timeoutPtr             = flag.Duration("timeout", 0, "abort after the given duration, 0 disables")
+	retriesPtr             = flag.Int("retries", -1, "number of retries for failed teamvault requests, -1 uses the config")
 	teamvaultKeyPtr        = flag.String("teamvault-key", "", "teamvault key")
 )
 
@@ -27,36 +31,49 @@ func main() {
 	flag.Parse()
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
-	err := do()
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := do(ctx)
 	if err != nil {
-		glog.Exit(err)
+		glog.Error(err)
+		glog.Flush()
+		os.Exit(teamvault.ExitCode(err))
 	}
 }
 
-func do() error {
-	teamvaultUrl := teamvault.Url(*teamvaultURLPtr)
-	teamvaultUser := teamvault.User(*teamvaultUserPtr)
-	teamvaultPassword := teamvault.Password(*teamvaultPassPtr)
+func do(ctx context.Context) error {
+	if *timeoutPtr > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeoutPtr)
+		defer cancel()
+	}
+	teamvaultConfig := &teamvault.TeamvaultConfig{
+		Url:      teamvault.Url(*teamvaultURLPtr),
+		User:     teamvault.User(*teamvaultUserPtr),
+		Password: teamvault.Password(*teamvaultPassPtr),
+	}
 	teamvaultConfigPath := teamvault.TeamvaultConfigPath(*teamvaultConfigPathPtr)
 	staging := teamvault.Staging(*stagingPtr)
 	if teamvaultConfigPath.Exists() {
-		teamvaultConfig, err := teamvaultConfigPath.Parse()
+		var err error
+		teamvaultConfig, err = teamvaultConfigPath.Parse()
 		if err != nil {
 			glog.V(2).Infof("parse teamvault config failed: %v", err)
 			return err
 		}
-		teamvaultUrl = teamvaultConfig.Url
-		teamvaultUser = teamvaultConfig.User
-		teamvaultPassword = teamvaultConfig.Password
 	}
-	httpClient := client_builder.New().WithTimeout(5 * time.Second).Build()
-	var teamvaultConnector teamvault.Connector
-	if !staging {
-		teamvaultConnector = connector.NewRemote(httpClient.Do, teamvaultUrl, teamvaultUser, teamvaultPassword)
-	} else {
-		teamvaultConnector = connector.NewDummy()
+	teamvaultFactory := factory.New(teamvaultConfig, staging).WithRetries(*retriesPtr)
+	defer func() {
+		if err := teamvaultFactory.Close(); err != nil {
+			glog.Warning(err)
+		}
+	}()
+	teamvaultConnector, err := teamvaultFactory.Connector()
+	if err != nil {
+		return err
 	}
-	result, err := teamvaultConnector.File(teamvault.Key(*teamvaultKeyPtr))
+	result, err := teamvaultConnector.File(ctx, teamvault.Key(*teamvaultKeyPtr))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/connector"
+	"github.com/bborbe/teamvault-utils/teamvaulttest"
+	"github.com/golang/glog"
+)
+
+var (
+	listenPtr     = flag.String("listen", "localhost:8080", "address to listen on")
+	fixturePtr    = flag.String("fixture", "", "JSON or YAML fixture file with the secrets to serve")
+	userPtr       = flag.String("user", "", "user required by basic auth, empty disables auth")
+	passPtr       = flag.String("pass", "", "password required by basic auth")
+	latencyPtr    = flag.Duration("latency", 0, "delay of every response")
+	pageSizePtr   = flag.Int("page-size", teamvaulttest.DefaultPageSize, "number of search results per page")
+	failEveryPtr  = flag.Int("fail-every", 0, "answer every nth request with -fail-status, 0 disables")
+	failStatusPtr = flag.Int("fail-status", http.StatusServiceUnavailable, "status of failed requests")
+)
+
+func main() {
+	defer glog.Flush()
+	glog.CopyStandardLogTo("info")
+	flag.Parse()
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := do(ctx)
+	if err != nil {
+		glog.Error(err)
+		glog.Flush()
+		os.Exit(teamvault.ExitCode(err))
+	}
+}
+
+func do(ctx context.Context) error {
+	if *fixturePtr == "" {
+		return fmt.Errorf("parameter fixture missing")
+	}
+	if *pageSizePtr < 1 {
+		return fmt.Errorf("parameter page-size must be at least 1")
+	}
+	store, err := connector.ReadFixture(*fixturePtr, nil)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Addr: *listenPtr,
+		Handler: teamvaulttest.NewServer(store).
+			WithBasicAuth(teamvault.User(*userPtr), teamvault.Password(*passPtr)).
+			WithLatency(*latencyPtr).
+			WithPageSize(*pageSizePtr).
+			WithFailEvery(*failEveryPtr, *failStatusPtr),
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			glog.Warningf("shutdown failed: %v", err)
+		}
+	}()
+	glog.V(0).Infof("serving %s on http://%s", *fixturePtr, *listenPtr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
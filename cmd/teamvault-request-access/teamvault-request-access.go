@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/connector"
+	"github.com/bborbe/teamvault-utils/factory"
+	"github.com/golang/glog"
+)
+
+var (
+	teamvaultUrlPtr        = flag.String("teamvault-url", "", "teamvault url")
+	teamvaultUserPtr       = flag.String("teamvault-user", "", "teamvault user")
+	teamvaultPassPtr       = flag.String("teamvault-pass", "", "teamvault password")
+	teamvaultConfigPathPtr = flag.String("teamvault-config", "", "teamvault config")
+	stagingPtr             = flag.Bool("staging", false, "staging status")
+	timeoutPtr             = flag.Duration("timeout", 0, "abort after the given duration, 0 disables")
+	retriesPtr             = flag.Int("retries", -1, "number of retries for failed teamvault requests, -1 uses the config")
+	teamvaultKeyPtr        = flag.String("teamvault-key", "", "teamvault key")
+	reasonPtr              = flag.String("reason", "", "why access is needed")
+	waitPtr                = flag.Bool("wait", false, "wait until the request is approved")
+	pollIntervalPtr        = flag.Duration("poll-interval", connector.DefaultAccessRequestPollInterval, "how often to check for approval")
+)
+
+func main() {
+	defer glog.Flush()
+	glog.CopyStandardLogTo("info")
+	flag.Parse()
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := do(ctx)
+	if err != nil {
+		glog.Error(err)
+		glog.Flush()
+		os.Exit(teamvault.ExitCode(err))
+	}
+}
+
+func do(ctx context.Context) error {
+	if *timeoutPtr > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeoutPtr)
+		defer cancel()
+	}
+	key := teamvault.Key(*teamvaultKeyPtr)
+	if key == "" {
+		return fmt.Errorf("parameter teamvault-key missing")
+	}
+	reason := teamvault.Reason(*reasonPtr)
+	if reason == "" {
+		return fmt.Errorf("parameter reason missing")
+	}
+	teamvaultConfig := &teamvault.TeamvaultConfig{
+		Url:      teamvault.Url(*teamvaultUrlPtr),
+		User:     teamvault.User(*teamvaultUserPtr),
+		Password: teamvault.Password(*teamvaultPassPtr),
+	}
+	teamvaultConfigPath := teamvault.TeamvaultConfigPath(*teamvaultConfigPathPtr)
+	staging := teamvault.Staging(*stagingPtr)
+	if teamvaultConfigPath.Exists() {
+		var err error
+		teamvaultConfig, err = teamvaultConfigPath.Parse()
+		if err != nil {
+			glog.V(2).Infof("parse teamvault config failed: %v", err)
+			return err
+		}
+	}
+	teamvaultFactory := factory.New(teamvaultConfig, staging).WithRetries(*retriesPtr)
+	defer func() {
+		if err := teamvaultFactory.Close(); err != nil {
+			glog.Warning(err)
+		}
+	}()
+	teamvaultConnector, err := teamvaultFactory.Base()
+	if err != nil {
+		return err
+	}
+	if err := teamvaultConnector.RequestAccess(ctx, key, reason); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "requested access to secret %v\n", key)
+	if !*waitPtr {
+		return nil
+	}
+	accessRequest := &connector.AccessRequest{
+		Connector:    teamvaultConnector,
+		Requester:    teamvaultConnector,
+		Reason:       reason,
+		PollInterval: *pollIntervalPtr,
+	}
+	if err := accessRequest.Wait(ctx, key); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "access to secret %v approved\n", key)
+	return nil
+}
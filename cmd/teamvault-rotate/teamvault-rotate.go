@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/factory"
+	"github.com/golang/glog"
+)
+
+var (
+	teamvaultUrlPtr        = flag.String("teamvault-url", "", "teamvault url")
+	teamvaultUserPtr       = flag.String("teamvault-user", "", "teamvault user")
+	teamvaultPassPtr       = flag.String("teamvault-pass", "", "teamvault password")
+	teamvaultConfigPathPtr = flag.String("teamvault-config", "", "teamvault config")
+	stagingPtr             = flag.Bool("staging", false, "staging status")
+	timeoutPtr             = flag.Duration("timeout", 0, "abort after the given duration, 0 disables")
+	teamvaultKeyPtr        = flag.String("teamvault-key", "", "teamvault key")
+	passwordFilePtr        = flag.String("password-file", "", "path of the file containing the new password, - reads stdin, generated if empty")
+	filePtr                = flag.String("file", "", "path of the file to upload as new revision")
+	lengthPtr              = flag.Int("length", teamvault.DefaultPasswordPolicy().Length, "length of the generated password")
+	lowercasePtr           = flag.Bool("lowercase", true, "generated password contains lowercase letters")
+	uppercasePtr           = flag.Bool("uppercase", true, "generated password contains uppercase letters")
+	digitsPtr              = flag.Bool("digits", true, "generated password contains digits")
+	symbolsPtr             = flag.Bool("symbols", true, "generated password contains symbols")
+)
+
+func main() {
+	defer glog.Flush()
+	glog.CopyStandardLogTo("info")
+	flag.Parse()
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := do(ctx)
+	if err != nil {
+		glog.Error(err)
+		glog.Flush()
+		os.Exit(teamvault.ExitCode(err))
+	}
+}
+
+func do(ctx context.Context) error {
+	if *timeoutPtr > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeoutPtr)
+		defer cancel()
+	}
+	teamvaultConfig := &teamvault.TeamvaultConfig{
+		Url:      teamvault.Url(*teamvaultUrlPtr),
+		User:     teamvault.User(*teamvaultUserPtr),
+		Password: teamvault.Password(*teamvaultPassPtr),
+	}
+	teamvaultConfigPath := teamvault.TeamvaultConfigPath(*teamvaultConfigPathPtr)
+	staging := teamvault.Staging(*stagingPtr)
+	if teamvaultConfigPath.Exists() {
+		var err error
+		teamvaultConfig, err = teamvaultConfigPath.Parse()
+		if err != nil {
+			glog.V(2).Infof("parse teamvault config failed: %v", err)
+			return err
+		}
+	}
+	data, err := secretData()
+	if err != nil {
+		return err
+	}
+	teamvaultFactory := factory.New(teamvaultConfig, staging)
+	defer func() {
+		if err := teamvaultFactory.Close(); err != nil {
+			glog.Warning(err)
+		}
+	}()
+	teamvaultWriter, err := teamvaultFactory.Base()
+	if err != nil {
+		return err
+	}
+	revision, err := teamvaultWriter.Update(ctx, teamvault.Key(*teamvaultKeyPtr), *data)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%v\n", revision)
+	return nil
+}
+
+func secretData() (*teamvault.SecretData, error) {
+	if *filePtr != "" && *passwordFilePtr != "" {
+		return nil, fmt.Errorf("-file and -password-file can not be combined")
+	}
+	if *filePtr != "" {
+		content, err := ioutil.ReadFile(*filePtr)
+		if err != nil {
+			glog.V(2).Infof("read file %s failed: %v", *filePtr, err)
+			return nil, err
+		}
+		return &teamvault.SecretData{
+			File: teamvault.File(base64.StdEncoding.EncodeToString(content)),
+		}, nil
+	}
+	if *passwordFilePtr != "" {
+		password, err := teamvault.ReadPasswordFile(*passwordFilePtr)
+		if err != nil {
+			return nil, err
+		}
+		return &teamvault.SecretData{
+			Password: password,
+		}, nil
+	}
+	password, err := teamvault.GeneratePassword(teamvault.PasswordPolicy{
+		Length:    *lengthPtr,
+		Lowercase: *lowercasePtr,
+		Uppercase: *uppercasePtr,
+		Digits:    *digitsPtr,
+		Symbols:   *symbolsPtr,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &teamvault.SecretData{
+		Password: password,
+	}, nil
+}
@@ -1,9 +1,24 @@
 package teamvault
 
+import "context"
+
 type Connector interface {
-	Password(key Key) (Password, error)
-	User(key Key) (User, error)
-	Url(key Key) (Url, error)
-	File(key Key) (File, error)
-	Search(name string) ([]Key, error)
+	Password(ctx context.Context, key Key) (Password, error)
+	User(ctx context.Context, key Key) (User, error)
+	Url(ctx context.Context, key Key) (Url, error)
+	File(ctx context.Context, key Key) (File, error)
+	CreditCard(ctx context.Context, key Key) (*CreditCard, error)
+	Search(ctx context.Context, options SearchOptions) ([]Secret, error)
+	Revisions(ctx context.Context, key Key) ([]Revision, error)
+	Secret(ctx context.Context, key Key) (*Secret, error)
+}
+
+type Writer interface {
+	Create(ctx context.Context, secret NewSecret) (Key, error)
+	Update(ctx context.Context, key Key, data SecretData) (TeamvaultCurrentRevision, error)
+}
+
+// AccessRequester asks the owners of a secret with access policy request for access.
+type AccessRequester interface {
+	RequestAccess(ctx context.Context, key Key, reason Reason) error
 }
@@ -0,0 +1,156 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bborbe/teamvault-utils"
+	"github.com/golang/glog"
+)
+
+const DefaultAccessRequestPollInterval = 10 * time.Second
+
+// AccessRequest files an access request with the given reason if reading
+// secret data fails with ErrAccessRequestRequired and waits until the
+// request is approved or the context ends.
+type AccessRequest struct {
+	Connector    teamvault.Connector
+	Requester    teamvault.AccessRequester
+	Reason       teamvault.Reason
+	PollInterval time.Duration
+
+	mux       sync.Mutex
+	requested map[teamvault.Key]bool
+}
+
+func (a *AccessRequest) Password(ctx context.Context, key teamvault.Key) (teamvault.Password, error) {
+	var result teamvault.Password
+	err := a.await(ctx, key, func() error {
+		var err error
+		result, err = a.Connector.Password(ctx, key)
+		return err
+	})
+	return result, err
+}
+
+func (a *AccessRequest) User(ctx context.Context, key teamvault.Key) (teamvault.User, error) {
+	return a.Connector.User(ctx, key)
+}
+
+func (a *AccessRequest) Url(ctx context.Context, key teamvault.Key) (teamvault.Url, error) {
+	return a.Connector.Url(ctx, key)
+}
+
+func (a *AccessRequest) File(ctx context.Context, key teamvault.Key) (teamvault.File, error) {
+	var result teamvault.File
+	err := a.await(ctx, key, func() error {
+		var err error
+		result, err = a.Connector.File(ctx, key)
+		return err
+	})
+	return result, err
+}
+
+func (a *AccessRequest) CreditCard(ctx context.Context, key teamvault.Key) (*teamvault.CreditCard, error) {
+	var result *teamvault.CreditCard
+	err := a.await(ctx, key, func() error {
+		var err error
+		result, err = a.Connector.CreditCard(ctx, key)
+		return err
+	})
+	return result, err
+}
+
+func (a *AccessRequest) Search(ctx context.Context, options teamvault.SearchOptions) ([]teamvault.Secret, error) {
+	return a.Connector.Search(ctx, options)
+}
+
+func (a *AccessRequest) Revisions(ctx context.Context, key teamvault.Key) ([]teamvault.Revision, error) {
+	return a.Connector.Revisions(ctx, key)
+}
+
+func (a *AccessRequest) Secret(ctx context.Context, key teamvault.Key) (*teamvault.Secret, error) {
+	return a.Connector.Secret(ctx, key)
+}
+
+// Wait blocks until the data of the secret can be read.
+func (a *AccessRequest) Wait(ctx context.Context, key teamvault.Key) error {
+	secret, err := a.Connector.Secret(ctx, key)
+	if err != nil {
+		return err
+	}
+	read := func() error {
+		var err error
+		switch secret.ContentType {
+		case teamvault.ContentTypeFile:
+			_, err = a.Connector.File(ctx, key)
+		case teamvault.ContentTypeCreditCard:
+			_, err = a.Connector.CreditCard(ctx, key)
+		default:
+			_, err = a.Connector.Password(ctx, key)
+		}
+		return err
+	}
+	if err := read(); !errors.Is(err, teamvault.ErrAccessRequestRequired) {
+		return err
+	}
+	return a.poll(ctx, key, read)
+}
+
+// await calls read and, if access has to be requested, files the request
+// once per secret and repeats read until it is approved.
+func (a *AccessRequest) await(ctx context.Context, key teamvault.Key, read func() error) error {
+	err := read()
+	if !errors.Is(err, teamvault.ErrAccessRequestRequired) {
+		return err
+	}
+	if err := a.request(ctx, key); err != nil {
+		return err
+	}
+	return a.poll(ctx, key, read)
+}
+
+func (a *AccessRequest) request(ctx context.Context, key teamvault.Key) error {
+	key, _ = key.Split()
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.requested[key] {
+		return nil
+	}
+	if a.Reason == "" {
+		return fmt.Errorf("secret %v %w, no reason given to request it", key, teamvault.ErrAccessRequestRequired)
+	}
+	if err := a.Requester.RequestAccess(ctx, key, a.Reason); err != nil {
+		return err
+	}
+	if a.requested == nil {
+		a.requested = make(map[teamvault.Key]bool)
+	}
+	a.requested[key] = true
+	fmt.Fprintf(os.Stderr, "requested access to secret %v, waiting for approval\n", key)
+	return nil
+}
+
+func (a *AccessRequest) poll(ctx context.Context, key teamvault.Key, read func() error) error {
+	interval := a.PollInterval
+	if interval <= 0 {
+		interval = DefaultAccessRequestPollInterval
+	}
+	for {
+		if err := sleep(ctx, interval); err != nil {
+			return err
+		}
+		err := read()
+		if !errors.Is(err, teamvault.ErrAccessRequestRequired) {
+			if err == nil {
+				glog.V(1).Infof("access to secret %v approved", key)
+			}
+			return err
+		}
+		glog.V(2).Infof("access to secret %v not yet approved", key)
+	}
+}
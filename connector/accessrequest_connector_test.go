@@ -0,0 +1,98 @@
+package connector_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/bborbe/assert"
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/connector"
+)
+
+func TestAccessRequestImplementsConnector(t *testing.T) {
+	c := &connector.AccessRequest{}
+	var i *teamvault.Connector
+	if err := AssertThat(c, Implements(i)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createRestricted(t *testing.T) (*connector.Memory, teamvault.Key) {
+	memory := connector.NewMemory()
+	key, err := memory.Create(context.Background(), teamvault.NewSecret{
+		ContentType:  teamvault.ContentTypePassword,
+		Name:         "restricted",
+		AccessPolicy: teamvault.AccessPolicyRequest,
+		Password:     "S3CR3T",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := memory.Restrict(key); err != nil {
+		t.Fatal(err)
+	}
+	return memory, key
+}
+
+func TestAccessRequestWaitsForApproval(t *testing.T) {
+	memory, key := createRestricted(t)
+	c := &connector.AccessRequest{
+		Connector:    memory,
+		Requester:    memory,
+		Reason:       "first deploy",
+		PollInterval: time.Millisecond,
+	}
+	go func() {
+		for len(memory.AccessRequests(key)) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		memory.Grant(key)
+	}()
+	password, err := c.Password(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password.String(), Is("S3CR3T")); err != nil {
+		t.Fatal(err)
+	}
+	reasons := memory.AccessRequests(key)
+	if err := AssertThat(len(reasons), Is(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(reasons[0].String(), Is("first deploy")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccessRequestWithoutReason(t *testing.T) {
+	memory, key := createRestricted(t)
+	c := &connector.AccessRequest{
+		Connector: memory,
+		Requester: memory,
+	}
+	_, err := c.Password(context.Background(), key)
+	if err := AssertThat(errors.Is(err, teamvault.ErrAccessRequestRequired), Is(true)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(len(memory.AccessRequests(key)), Is(0)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccessRequestStopsWithContext(t *testing.T) {
+	memory, key := createRestricted(t)
+	c := &connector.AccessRequest{
+		Connector:    memory,
+		Requester:    memory,
+		Reason:       "first deploy",
+		PollInterval: time.Millisecond,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := c.Wait(ctx, key)
+	if err := AssertThat(errors.Is(err, context.DeadlineExceeded), Is(true)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,75 +1,236 @@
 package connector
 
 import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/metrics"
+	"github.com/bborbe/teamvault-utils/transport"
+	"github.com/golang/glog"
 )
 
+// Cache keeps successful results in memory. It is safe for concurrent use,
+// concurrent misses of the same entry are read only once. Every caller gets
+// its own copy of credit cards, secrets and lists.
 type Cache struct {
 	Connector teamvault.Connector
-	Passwords map[teamvault.Key]teamvault.Password
-	Users     map[teamvault.Key]teamvault.User
-	Urls      map[teamvault.Key]teamvault.Url
-	Files     map[teamvault.Key]teamvault.File
+	Metrics   *metrics.Registry
+	// TTL after which an entry is read again, zero keeps entries forever.
+	TTL time.Duration
+	// StaleWhileRevalidate returns an expired entry for this long after
+	// its TTL while it is read again in the background.
+	StaleWhileRevalidate time.Duration
+	// RefreshTimeout limits reading an entry again in the background,
+	// transport.DefaultRequestTimeout if zero.
+	RefreshTimeout time.Duration
+
+	mux     sync.Mutex
+	entries map[string]*cacheEntry
+	flight  memo
+	now     func() time.Time
+}
+
+type cacheEntry struct {
+	value      interface{}
+	expires    time.Time
+	refreshing bool
 }
 
 func NewCache(connector teamvault.Connector) *Cache {
 	return &Cache{
 		Connector: connector,
-		Passwords: make(map[teamvault.Key]teamvault.Password),
-		Users:     make(map[teamvault.Key]teamvault.User),
-		Urls:      make(map[teamvault.Key]teamvault.Url),
-		Files:     make(map[teamvault.Key]teamvault.File),
 	}
 }
 
-func (c *Cache) Password(key teamvault.Key) (teamvault.Password, error) {
-	value, ok := c.Passwords[key]
-	if ok {
-		return value, nil
+// WithTTL sets TTL and StaleWhileRevalidate.
+func (c *Cache) WithTTL(ttl time.Duration, staleWhileRevalidate time.Duration) *Cache {
+	c.TTL = ttl
+	c.StaleWhileRevalidate = staleWhileRevalidate
+	return c
+}
+
+// WithRefreshTimeout sets RefreshTimeout, e.g. to the request timeout of
+// the transport.
+func (c *Cache) WithRefreshTimeout(timeout time.Duration) *Cache {
+	c.RefreshTimeout = timeout
+	return c
+}
+
+func (c *Cache) Password(ctx context.Context, key teamvault.Key) (teamvault.Password, error) {
+	value, err := c.get(ctx, "password", key.String(), func(ctx context.Context) (interface{}, error) {
+		return c.Connector.Password(ctx, key)
+	})
+	if err != nil {
+		return "", err
+	}
+	return value.(teamvault.Password), nil
+}
+
+func (c *Cache) User(ctx context.Context, key teamvault.Key) (teamvault.User, error) {
+	value, err := c.get(ctx, "user", key.String(), func(ctx context.Context) (interface{}, error) {
+		return c.Connector.User(ctx, key)
+	})
+	if err != nil {
+		return "", err
 	}
-	value, err := c.Connector.Password(key)
-	if err == nil {
-		c.Passwords[key] = value
+	return value.(teamvault.User), nil
+}
+
+func (c *Cache) Url(ctx context.Context, key teamvault.Key) (teamvault.Url, error) {
+	value, err := c.get(ctx, "url", key.String(), func(ctx context.Context) (interface{}, error) {
+		return c.Connector.Url(ctx, key)
+	})
+	if err != nil {
+		return "", err
 	}
-	return value, err
+	return value.(teamvault.Url), nil
 }
 
-func (c *Cache) User(key teamvault.Key) (teamvault.User, error) {
-	value, ok := c.Users[key]
-	if ok {
-		return value, nil
+func (c *Cache) File(ctx context.Context, key teamvault.Key) (teamvault.File, error) {
+	value, err := c.get(ctx, "file", key.String(), func(ctx context.Context) (interface{}, error) {
+		return c.Connector.File(ctx, key)
+	})
+	if err != nil {
+		return "", err
 	}
-	value, err := c.Connector.User(key)
-	if err == nil {
-		c.Users[key] = value
+	return value.(teamvault.File), nil
+}
+
+func (c *Cache) CreditCard(ctx context.Context, key teamvault.Key) (*teamvault.CreditCard, error) {
+	value, err := c.get(ctx, "creditcard", key.String(), func(ctx context.Context) (interface{}, error) {
+		return c.Connector.CreditCard(ctx, key)
+	})
+	if err != nil {
+		return nil, err
 	}
-	return value, err
+	creditCard := *value.(*teamvault.CreditCard)
+	return &creditCard, nil
 }
 
-func (c *Cache) Url(key teamvault.Key) (teamvault.Url, error) {
-	value, ok := c.Urls[key]
-	if ok {
-		return value, nil
+func (c *Cache) Secret(ctx context.Context, key teamvault.Key) (*teamvault.Secret, error) {
+	value, err := c.get(ctx, "secret", key.String(), func(ctx context.Context) (interface{}, error) {
+		return c.Connector.Secret(ctx, key)
+	})
+	if err != nil {
+		return nil, err
 	}
-	value, err := c.Connector.Url(key)
-	if err == nil {
-		c.Urls[key] = value
+	secret := *value.(*teamvault.Secret)
+	return &secret, nil
+}
+
+func (c *Cache) Search(ctx context.Context, options teamvault.SearchOptions) ([]teamvault.Secret, error) {
+	value, err := c.get(ctx, "search", fmt.Sprintf("%+v", options), func(ctx context.Context) (interface{}, error) {
+		return c.Connector.Search(ctx, options)
+	})
+	if err != nil {
+		return nil, err
 	}
-	return value, err
+	return append([]teamvault.Secret(nil), value.([]teamvault.Secret)...), nil
 }
 
-func (c *Cache) File(key teamvault.Key) (teamvault.File, error) {
-	value, ok := c.Files[key]
-	if ok {
-		return value, nil
+func (c *Cache) Revisions(ctx context.Context, key teamvault.Key) ([]teamvault.Revision, error) {
+	value, err := c.get(ctx, "revisions", key.String(), func(ctx context.Context) (interface{}, error) {
+		return c.Connector.Revisions(ctx, key)
+	})
+	if err != nil {
+		return nil, err
 	}
-	value, err := c.Connector.File(key)
-	if err == nil {
-		c.Files[key] = value
+	return append([]teamvault.Revision(nil), value.([]teamvault.Revision)...), nil
+}
+
+// Len returns the number of entries, expired ones included.
+func (c *Cache) Len() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return len(c.entries)
+}
+
+// Purge removes all entries.
+func (c *Cache) Purge() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.entries = nil
+}
+
+// get returns the entry if fresh, or stale while it is read again in the
+// background. Otherwise it reads the entry, once for all concurrent callers.
+func (c *Cache) get(ctx context.Context, kind string, key string, fetch func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	id := kind + "/" + key
+	now := c.clock()
+	c.mux.Lock()
+	entry, ok := c.entries[id]
+	switch {
+	case ok && (entry.expires.IsZero() || now.Before(entry.expires)):
+		c.mux.Unlock()
+		c.count(kind, "hit")
+		return entry.value, nil
+	case ok && now.Before(entry.expires.Add(c.StaleWhileRevalidate)):
+		refresh := !entry.refreshing
+		entry.refreshing = true
+		c.mux.Unlock()
+		c.count(kind, "stale")
+		if refresh {
+			go c.refresh(ctx, id, fetch)
+		}
+		return entry.value, nil
+	}
+	c.mux.Unlock()
+	c.count(kind, "miss")
+	return c.flight.share(ctx, id, func() (interface{}, error) {
+		return c.load(ctx, id, fetch)
+	})
+}
+
+// refresh reads the entry again, not canceled with the request that found it
+// stale but limited by RefreshTimeout.
+func (c *Cache) refresh(ctx context.Context, id string, fetch func(ctx context.Context) (interface{}, error)) {
+	timeout := c.RefreshTimeout
+	if timeout <= 0 {
+		timeout = transport.DefaultRequestTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+	_, err := c.flight.share(ctx, id, func() (interface{}, error) {
+		return c.load(ctx, id, fetch)
+	})
+	if err != nil {
+		glog.V(2).Infof("refresh %s failed: %v", id, err)
+		c.mux.Lock()
+		if entry, ok := c.entries[id]; ok {
+			entry.refreshing = false
+		}
+		c.mux.Unlock()
+	}
+}
+
+func (c *Cache) load(ctx context.Context, id string, fetch func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	value, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entry := &cacheEntry{value: value}
+	if c.TTL > 0 {
+		entry.expires = c.clock().Add(c.TTL)
+	}
+	c.mux.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]*cacheEntry)
+	}
+	c.entries[id] = entry
+	c.mux.Unlock()
+	return value, nil
+}
+
+func (c *Cache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
 	}
-	return value, err
+	return time.Now()
 }
 
-func (c *Cache) Search(key string) ([]teamvault.Key, error) {
-	return c.Connector.Search(key)
+func (c *Cache) count(kind string, result string) {
+	c.Metrics.Inc("teamvault_cache_requests_total", "Reads of the in memory cache.", metrics.Labels{"kind": kind, "result": result})
 }
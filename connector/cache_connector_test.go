@@ -0,0 +1,295 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/bborbe/assert"
+	"github.com/bborbe/teamvault-utils"
+)
+
+type countingConnector struct {
+	teamvault.Connector
+	calls   int32
+	release chan struct{}
+	err     error
+}
+
+func (c *countingConnector) Password(ctx context.Context, key teamvault.Key) (teamvault.Password, error) {
+	n := atomic.AddInt32(&c.calls, 1)
+	if c.release != nil {
+		<-c.release
+	}
+	if c.err != nil {
+		return "", c.err
+	}
+	return teamvault.Password(fmt.Sprintf("%s-%d", key, n)), nil
+}
+
+func (c *countingConnector) Search(ctx context.Context, options teamvault.SearchOptions) ([]teamvault.Secret, error) {
+	atomic.AddInt32(&c.calls, 1)
+	return c.Connector.Search(ctx, options)
+}
+
+func (c *countingConnector) count() int32 {
+	return atomic.LoadInt32(&c.calls)
+}
+
+type fakeClock struct {
+	mux sync.Mutex
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Add(d time.Duration) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.now = f.now.Add(d)
+}
+
+func TestCacheImplementsConnector(t *testing.T) {
+	c := NewCache(NewDummy())
+	var i *teamvault.Connector
+	if err := AssertThat(c, Implements(i)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheKeepsValueWithoutTTL(t *testing.T) {
+	counter := &countingConnector{Connector: NewDummy()}
+	cache := NewCache(counter)
+	for i := 0; i < 3; i++ {
+		password, err := cache.Password(context.Background(), "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := AssertThat(password, Is(teamvault.Password("key-1"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := AssertThat(counter.count(), Is(int32(1))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheExpiresAfterTTL(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	counter := &countingConnector{Connector: NewDummy()}
+	cache := NewCache(counter).WithTTL(time.Minute, 0)
+	cache.now = clock.Now
+	if _, err := cache.Password(context.Background(), "key"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(30 * time.Second)
+	if _, err := cache.Password(context.Background(), "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(counter.count(), Is(int32(1))); err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(time.Minute)
+	password, err := cache.Password(context.Background(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("key-2"))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheDoesNotKeepErrors(t *testing.T) {
+	counter := &countingConnector{Connector: NewDummy(), err: teamvault.ErrNotFound}
+	cache := NewCache(counter)
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Password(context.Background(), "key"); err == nil {
+			t.Fatal("error expected")
+		}
+	}
+	if err := AssertThat(counter.count(), Is(int32(2))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheReadsConcurrentMissesOnce(t *testing.T) {
+	counter := &countingConnector{Connector: NewDummy(), release: make(chan struct{})}
+	cache := NewCache(counter)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.Password(context.Background(), "key"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(counter.release)
+	wg.Wait()
+	if err := AssertThat(counter.count(), Is(int32(1))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	counter := &countingConnector{Connector: NewDummy()}
+	cache := NewCache(counter).WithTTL(time.Minute, time.Hour)
+	cache.now = clock.Now
+	if _, err := cache.Password(context.Background(), "key"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(2 * time.Minute)
+	password, err := cache.Password(context.Background(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("key-1"))); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100 && counter.count() < 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 100; i++ {
+		password, err = cache.Password(context.Background(), "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if password == "key-2" {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("key-2"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(counter.count(), Is(int32(2))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheSearch(t *testing.T) {
+	memory := NewMemory()
+	if _, err := memory.Create(context.Background(), teamvault.NewSecret{Name: "db", ContentType: teamvault.ContentTypePassword, Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	counter := &countingConnector{Connector: memory}
+	cache := NewCache(counter)
+	for i := 0; i < 2; i++ {
+		secrets, err := cache.Search(context.Background(), teamvault.SearchOptions{Name: "db"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := AssertThat(len(secrets), Is(1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := cache.Search(context.Background(), teamvault.SearchOptions{Name: "other"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(counter.count(), Is(int32(2))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemory()
+	key, err := memory.Create(ctx, teamvault.NewSecret{
+		Name:        "card",
+		ContentType: teamvault.ContentTypeCreditCard,
+		CreditCard: &teamvault.CreditCard{
+			Holder:          "Jane",
+			Number:          "4111111111111111",
+			ExpirationMonth: "12",
+			ExpirationYear:  "2030",
+			SecurityCode:    "123",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := NewCache(memory)
+	creditCard, err := cache.CreditCard(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creditCard.Holder = "changed"
+	secret, err := cache.Secret(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret.Name = "changed"
+	secrets, err := cache.Search(ctx, teamvault.SearchOptions{Name: "card"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets[0].Name = "changed"
+	if creditCard, err = cache.CreditCard(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(creditCard.Holder, Is("Jane")); err != nil {
+		t.Fatal(err)
+	}
+	if secret, err = cache.Secret(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(secret.Name, Is(teamvault.Name("card"))); err != nil {
+		t.Fatal(err)
+	}
+	if secrets, err = cache.Search(ctx, teamvault.SearchOptions{Name: "card"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(secrets[0].Name, Is(teamvault.Name("card"))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// hangingConnector answers the first read and hangs on all others until the
+// context ends.
+type hangingConnector struct {
+	teamvault.Connector
+	calls int32
+}
+
+func (c *hangingConnector) Password(ctx context.Context, key teamvault.Key) (teamvault.Password, error) {
+	if atomic.AddInt32(&c.calls, 1) == 1 {
+		return "S3CR3T", nil
+	}
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestCacheRefreshTimeout(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	hanging := &hangingConnector{Connector: NewDummy()}
+	cache := NewCache(hanging).WithTTL(time.Minute, time.Hour).WithRefreshTimeout(10 * time.Millisecond)
+	cache.now = clock.Now
+	if _, err := cache.Password(context.Background(), "key"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(2 * time.Minute)
+	// the next refresh starts only once the hanging one timed out
+	for i := 0; i < 1000 && atomic.LoadInt32(&hanging.calls) < 3; i++ {
+		password, err := cache.Password(context.Background(), "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := AssertThat(password, Is(teamvault.Password("S3CR3T"))); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := AssertThat(atomic.LoadInt32(&hanging.calls) >= 3, Is(true)); err != nil {
+		t.Fatal(err)
+	}
+}
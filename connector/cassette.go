@@ -0,0 +1,68 @@
+package connector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/bborbe/teamvault-utils"
+)
+
+// ErrNotRecorded is returned by Replay for calls missing in the cassette.
+var ErrNotRecorded = errors.New("not recorded")
+
+// Cassette holds the results of connector calls by method and argument.
+type Cassette struct {
+	Entries map[string]CassetteEntry `json:"entries"`
+}
+
+// CassetteEntry is the result of one call. Error is the type of the error
+// as named by teamvault.ErrorType.
+type CassetteEntry struct {
+	Value   json.RawMessage `json:"value,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Message string          `json:"message,omitempty"`
+}
+
+// ReadCassette reads a cassette written by Recorder.Save.
+func ReadCassette(path string) (*Cassette, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cassette %s failed: %v", path, err)
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(content, &cassette); err != nil {
+		return nil, fmt.Errorf("parse cassette %s failed: %v", path, err)
+	}
+	return &cassette, nil
+}
+
+// Write writes the cassette with entries sorted, so recordings diff well.
+func (c *Cassette) Write(path string) error {
+	content, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(content, '\n'), 0600)
+}
+
+func cassetteKey(method string, argument string) string {
+	return method + " " + argument
+}
+
+// errorOfType returns an error errors.Is matches like the recorded one.
+func errorOfType(errorType string, message string) error {
+	for _, kind := range []error{
+		teamvault.ErrNotFound,
+		teamvault.ErrUnauthorized,
+		teamvault.ErrForbidden,
+		teamvault.ErrAccessRequestRequired,
+		teamvault.ErrServerUnavailable,
+	} {
+		if teamvault.ErrorType(kind) == errorType {
+			return fmt.Errorf("%s: %w", message, kind)
+		}
+	}
+	return errors.New(message)
+}
@@ -1,99 +1,291 @@
 package connector
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/metrics"
 	"github.com/golang/glog"
 	"github.com/pkg/errors"
 )
 
+// DiskFallback stores every value read on disk and returns it if Teamvault
+// is unavailable. Usage of the fallback is counted in Metrics if set.
+// With Key the entries are encrypted and plaintext entries are refused
+// until encrypted with Migrate.
 type DiskFallback struct {
 	Connector teamvault.Connector
+	Metrics   *metrics.Registry
+	Key       *DiskKey
+	// Dir entries are stored in, DefaultDiskFallbackDir if empty.
+	Dir string
+	// MaxAge refuses entries fetched longer ago, zero serves them forever.
+	MaxAge time.Duration
+
+	revisions memo
 }
 
-func (d *DiskFallback) Password(key teamvault.Key) (teamvault.Password, error) {
-	kind := "password"
-	content, err := d.Connector.Password(key)
-	if err != nil {
-		content, err := read(key, kind)
-		if err == nil {
-			return teamvault.Password(content), nil
-		}
+// DiskEntry is stored for every value read, encrypted if a key is set.
+type DiskEntry struct {
+	// Fetched is zero for entries written by earlier versions.
+	Fetched  time.Time `json:"fetched"`
+	Revision string    `json:"revision,omitempty"`
+	Value    []byte    `json:"value"`
+}
+
+// diskEntryMagic starts every entry with metadata, entries without it
+// contain only the value.
+var diskEntryMagic = []byte("\x00TVE1")
+
+func (d *DiskFallback) Password(ctx context.Context, key teamvault.Key) (teamvault.Password, error) {
+	content, err := d.get(ctx, key, "password", func() ([]byte, error) {
+		password, err := d.Connector.Password(ctx, key)
+		return []byte(password), err
+	})
+	return teamvault.Password(content), err
+}
+
+func (d *DiskFallback) User(ctx context.Context, key teamvault.Key) (teamvault.User, error) {
+	content, err := d.get(ctx, key, "user", func() ([]byte, error) {
+		user, err := d.Connector.User(ctx, key)
+		return []byte(user), err
+	})
+	return teamvault.User(content), err
+}
+
+func (d *DiskFallback) Url(ctx context.Context, key teamvault.Key) (teamvault.Url, error) {
+	content, err := d.get(ctx, key, "url", func() ([]byte, error) {
+		url, err := d.Connector.Url(ctx, key)
+		return []byte(url), err
+	})
+	return teamvault.Url(content), err
+}
+
+func (d *DiskFallback) File(ctx context.Context, key teamvault.Key) (teamvault.File, error) {
+	content, err := d.get(ctx, key, "file", func() ([]byte, error) {
+		file, err := d.Connector.File(ctx, key)
+		return []byte(file), err
+	})
+	return teamvault.File(content), err
+}
+
+func (d *DiskFallback) CreditCard(ctx context.Context, key teamvault.Key) (*teamvault.CreditCard, error) {
+	var result teamvault.CreditCard
+	if err := d.getJson(ctx, key, "creditcard", &result, func() (interface{}, error) {
+		return d.Connector.CreditCard(ctx, key)
+	}); err != nil {
+		return nil, err
 	}
-	if write(key, kind, []byte(content)) != nil {
-		glog.Warningf("write teamvault diskfallback failed")
+	return &result, nil
+}
+
+func (d *DiskFallback) Secret(ctx context.Context, key teamvault.Key) (*teamvault.Secret, error) {
+	var result teamvault.Secret
+	if err := d.getJson(ctx, key, "secret", &result, func() (interface{}, error) {
+		return d.Connector.Secret(ctx, key)
+	}); err != nil {
+		return nil, err
 	}
-	return content, err
+	return &result, nil
 }
 
-func (d *DiskFallback) User(key teamvault.Key) (teamvault.User, error) {
-	kind := "user"
-	content, err := d.Connector.User(key)
-	if err != nil {
-		content, err := read(key, kind)
-		if err == nil {
-			return teamvault.User(content), nil
+func (d *DiskFallback) Search(ctx context.Context, options teamvault.SearchOptions) ([]teamvault.Secret, error) {
+	return d.Connector.Search(ctx, options)
+}
+
+func (d *DiskFallback) Revisions(ctx context.Context, key teamvault.Key) ([]teamvault.Revision, error) {
+	return d.Connector.Revisions(ctx, key)
+}
+
+// get stores the value fetched or returns the stored value if Teamvault is
+// unavailable. Values are only stored after a successful fetch, all other
+// errors, e.g. not found or forbidden, are returned as they are.
+func (d *DiskFallback) get(ctx context.Context, key teamvault.Key, kind string, fetch func() ([]byte, error)) ([]byte, error) {
+	content, err := fetch()
+	if ctx.Err() != nil {
+		return content, err
+	}
+	if err != nil && teamvault.ExitCode(err) != teamvault.ExitServerUnavailable {
+		return nil, err
+	}
+	if err == nil {
+		entry := DiskEntry{
+			Fetched:  time.Now(),
+			Revision: d.revision(ctx, key),
+			Value:    content,
+		}
+		if err := d.write(key, kind, entry); err != nil {
+			glog.Warningf("write teamvault diskfallback failed: %v", err)
 		}
+		return content, nil
 	}
-	if write(key, kind, []byte(content)) != nil {
-		glog.Warningf("write teamvault diskfallback failed")
+	entry, readErr := d.read(key, kind)
+	if readErr != nil {
+		glog.V(2).Infof("read teamvault diskfallback failed: %v", readErr)
+		d.count(kind, "failed")
+		return nil, err
 	}
-	return content, err
+	age := time.Since(entry.Fetched)
+	if d.MaxAge > 0 && (entry.Fetched.IsZero() || age > d.MaxAge) {
+		glog.Warningf("teamvault diskfallback %s of %v is older than %v, refused", kind, key, d.MaxAge)
+		d.count(kind, "expired")
+		return nil, err
+	}
+	d.count(kind, "used")
+	if entry.Fetched.IsZero() {
+		fmt.Fprintf(os.Stderr, "WARNING: teamvault failed (%v), using %s of %v stored on disk of unknown age\n", err, kind, key)
+	} else {
+		fmt.Fprintf(os.Stderr, "WARNING: teamvault failed (%v), using %s of %v fetched %v ago, revision %s\n", err, kind, key, age.Round(time.Second), entry.Revision)
+	}
+	return entry.Value, nil
 }
 
-func (d *DiskFallback) Url(key teamvault.Key) (teamvault.Url, error) {
-	kind := "url"
-	content, err := d.Connector.Url(key)
-	if err != nil {
-		content, err := read(key, kind)
-		if err == nil {
-			return teamvault.Url(content), nil
+func (d *DiskFallback) getJson(ctx context.Context, key teamvault.Key, kind string, value interface{}, fetch func() (interface{}, error)) error {
+	content, err := d.get(ctx, key, kind, func() ([]byte, error) {
+		result, err := fetch()
+		if err != nil {
+			return nil, err
 		}
+		return json.Marshal(result)
+	})
+	if err != nil {
+		return err
 	}
-	if write(key, kind, []byte(content)) != nil {
-		glog.Warningf("write teamvault diskfallback failed")
-	}
-	return content, err
+	return json.Unmarshal(content, value)
 }
 
-func (d *DiskFallback) File(key teamvault.Key) (teamvault.File, error) {
-	kind := "file"
-	content, err := d.Connector.File(key)
-	if err != nil {
-		content, err := read(key, kind)
-		if err == nil {
-			return teamvault.File(content), nil
-		}
+// revision returns the revision pinned in the key or the current revision
+// of the secret, read once per key. Empty if unknown.
+func (d *DiskFallback) revision(ctx context.Context, key teamvault.Key) string {
+	if _, revision := key.Split(); revision != "" {
+		return revision.String()
 	}
-	if write(key, kind, []byte(content)) != nil {
-		glog.Warningf("write teamvault diskfallback failed")
+	value, err := d.revisions.do(ctx, key.String(), func() (interface{}, error) {
+		secret, err := d.Connector.Secret(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if secret == nil {
+			return "", nil
+		}
+		return secret.CurrentRevision.String(), nil
+	})
+	if err != nil {
+		return ""
 	}
-	return content, err
+	return value.(string)
+}
+
+// count records whether the fallback saved a failed read.
+func (d *DiskFallback) count(kind string, result string) {
+	d.Metrics.Inc("teamvault_diskfallback_total", "Reads served from the disk fallback after the connector failed.", metrics.Labels{"kind": kind, "result": result})
+}
+
+// DefaultDiskFallbackDir is the directory DiskFallback stores entries in.
+func DefaultDiskFallbackDir() string {
+	return filepath.Join(os.Getenv("HOME"), ".teamvault-cache")
 }
 
-func (d *DiskFallback) Search(key string) ([]teamvault.Key, error) {
-	return d.Connector.Search(key)
+func (d *DiskFallback) dir() string {
+	if d.Dir != "" {
+		return d.Dir
+	}
+	return DefaultDiskFallbackDir()
 }
 
-func cachefile(key teamvault.Key, kind string) string {
-	return filepath.Join(os.Getenv("HOME"), ".teamvault-cache", key.String(), kind)
+func (d *DiskFallback) cachefile(key teamvault.Key, kind string) string {
+	return filepath.Join(d.cachedir(key), kind)
 }
 
-func cachedir(key teamvault.Key) string {
-	return filepath.Join(os.Getenv("HOME"), ".teamvault-cache", key.String())
+func (d *DiskFallback) cachedir(key teamvault.Key) string {
+	return filepath.Join(d.dir(), key.String())
 }
 
-func read(key teamvault.Key, kind string) ([]byte, error) {
-	return ioutil.ReadFile(cachefile(key, kind))
+func (d *DiskFallback) read(key teamvault.Key, kind string) (*DiskEntry, error) {
+	content, err := ioutil.ReadFile(d.cachefile(key, kind))
+	if err != nil {
+		return nil, err
+	}
+	name := key.String() + "/" + kind
+	if !encrypted(content) {
+		if d.Key != nil {
+			// anyone able to write the directory could plant it
+			return nil, fmt.Errorf("entry %s is not encrypted, run teamvault-cache migrate", name)
+		}
+		return decodeDiskEntry(content)
+	}
+	if d.Key == nil {
+		return nil, fmt.Errorf("entry %s is encrypted but no key is configured", name)
+	}
+	content, err = d.Key.open(name, content)
+	if err != nil {
+		glog.Warningf("read teamvault diskfallback failed: %v", err)
+		return nil, err
+	}
+	return decodeDiskEntry(content)
 }
 
-func write(key teamvault.Key, kind string, content []byte) error {
-	err := os.MkdirAll(cachedir(key), 0700)
+func (d *DiskFallback) write(key teamvault.Key, kind string, entry DiskEntry) error {
+	err := os.MkdirAll(d.cachedir(key), 0700)
 	if err != nil {
-		return errors.Wrap(err, "mkdir %s failed")
+		return errors.Wrapf(err, "mkdir %s failed", d.cachedir(key))
 	}
-	return errors.Wrap(ioutil.WriteFile(cachefile(key, kind), content, 0600), "write cache file failed")
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "marshal failed")
+	}
+	content = append(append([]byte{}, diskEntryMagic...), content...)
+	if d.Key != nil {
+		content, err = d.Key.seal(key.String()+"/"+kind, content)
+		if err != nil {
+			return err
+		}
+	}
+	return errors.Wrap(ioutil.WriteFile(d.cachefile(key, kind), content, 0600), "write cache file failed")
+}
+
+// decodeDiskEntry reads the entry, content of earlier versions is the value.
+func decodeDiskEntry(content []byte) (*DiskEntry, error) {
+	if !bytes.HasPrefix(content, diskEntryMagic) {
+		return &DiskEntry{Value: content}, nil
+	}
+	var entry DiskEntry
+	if err := json.Unmarshal(content[len(diskEntryMagic):], &entry); err != nil {
+		return nil, errors.Wrap(err, "unmarshal entry failed")
+	}
+	return &entry, nil
+}
+
+// Migrate encrypts all plaintext entries with the key and returns their number.
+func (d *DiskFallback) Migrate() (int, error) {
+	if d.Key == nil {
+		return 0, fmt.Errorf("no key configured")
+	}
+	var count int
+	err := d.walk(func(key teamvault.Key, kind string) error {
+		content, err := ioutil.ReadFile(d.cachefile(key, kind))
+		if err != nil {
+			return err
+		}
+		if encrypted(content) {
+			return nil
+		}
+		entry, err := decodeDiskEntry(content)
+		if err != nil {
+			return err
+		}
+		if err := d.write(key, kind, *entry); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	return count, err
 }
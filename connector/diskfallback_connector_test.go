@@ -1,7 +1,15 @@
 package connector_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/bborbe/assert"
 	"github.com/bborbe/teamvault-utils"
@@ -15,3 +23,161 @@ func TestDiskFallbackConnctorImplementsConnector(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestDiskFallbackStoresOnlyFetchedValues(t *testing.T) {
+	dir := t.TempDir()
+	fallback := &connector.DiskFallback{Connector: connector.NewMemory(), Dir: dir}
+	if _, err := fallback.Password(context.Background(), "missing"); err == nil {
+		t.Fatal("error expected")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing", "password")); !os.IsNotExist(err) {
+		t.Fatalf("no entry expected, got %v", err)
+	}
+}
+
+func TestDiskFallbackStoresMetadata(t *testing.T) {
+	dir := t.TempDir()
+	memory := connector.NewMemory()
+	fallback := &connector.DiskFallback{Connector: memory, Dir: dir}
+	key := storeUnavailable(t, fallback, memory)
+	content, err := ioutil.ReadFile(filepath.Join(dir, key.String(), "password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`"fetched":`, `"revision":`} {
+		if err := AssertThat(strings.Contains(string(content), expected), Is(true)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDiskFallbackMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "key123"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	// entries of earlier versions have no fetch time
+	if err := ioutil.WriteFile(filepath.Join(dir, "key123", "password"), []byte("S3CR3T"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	memory := connector.NewMemory()
+	memory.Fail("key123", teamvault.ErrServerUnavailable)
+	password, err := (&connector.DiskFallback{Connector: memory, Dir: dir}).Password(context.Background(), "key123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("S3CR3T"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&connector.DiskFallback{Connector: memory, Dir: dir, MaxAge: time.Hour}).Password(context.Background(), "key123"); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestDiskFallbackServesFreshEntries(t *testing.T) {
+	dir := t.TempDir()
+	memory := connector.NewMemory()
+	fallback := &connector.DiskFallback{Connector: memory, Dir: dir, MaxAge: time.Hour}
+	key := storeUnavailable(t, fallback, memory)
+	password, err := fallback.Password(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("S3CR3T"))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// interrupted cancels the context once the password was read.
+type interrupted struct {
+	teamvault.Connector
+	cancel context.CancelFunc
+}
+
+func (i *interrupted) Password(ctx context.Context, key teamvault.Key) (teamvault.Password, error) {
+	defer i.cancel()
+	return i.Connector.Password(ctx, key)
+}
+
+func TestDiskFallbackReturnsValueFetchedBeforeCancel(t *testing.T) {
+	memory := connector.NewMemory()
+	key, err := memory.Create(context.Background(), teamvault.NewSecret{ContentType: teamvault.ContentTypePassword, Name: "db", Password: "S3CR3T"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	fallback := &connector.DiskFallback{Connector: &interrupted{Connector: memory, cancel: cancel}, Dir: t.TempDir()}
+	password, err := fallback.Password(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("S3CR3T"))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// secretCounter counts the reads of secret records.
+type secretCounter struct {
+	teamvault.Connector
+	count int
+}
+
+func (s *secretCounter) Secret(ctx context.Context, key teamvault.Key) (*teamvault.Secret, error) {
+	s.count++
+	return s.Connector.Secret(ctx, key)
+}
+
+func TestDiskFallbackReadsRevisionOncePerKey(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	memory := connector.NewMemory()
+	key, err := memory.Create(ctx, teamvault.NewSecret{ContentType: teamvault.ContentTypePassword, Name: "db", User: "admin", Password: "S3CR3T"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := &secretCounter{Connector: memory}
+	fallback := &connector.DiskFallback{Connector: counter, Dir: dir}
+	for i := 0; i < 2; i++ {
+		if _, err := fallback.Password(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fallback.User(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := AssertThat(counter.count, Is(1)); err != nil {
+		t.Fatal(err)
+	}
+	revisions, err := memory.Revisions(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinned := key.WithRevision(revisions[0].Id)
+	if _, err := fallback.Password(ctx, pinned); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(counter.count, Is(1)); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := fallback.Entry(pinned, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(entry.Revision, Is(revisions[0].Id.String())); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiskFallbackOnlyServesIfUnavailable(t *testing.T) {
+	for _, kind := range []error{teamvault.ErrUnauthorized, teamvault.ErrForbidden, teamvault.ErrNotFound} {
+		t.Run(kind.Error(), func(t *testing.T) {
+			memory := connector.NewMemory()
+			fallback := &connector.DiskFallback{Connector: memory, Dir: t.TempDir()}
+			key := storeUnavailable(t, fallback, memory)
+			memory.Fail(key, fmt.Errorf("get %v failed: %w", key, kind))
+			if _, err := fallback.Password(context.Background(), key); !errors.Is(err, kind) {
+				t.Fatalf("%v expected, got %v", kind, err)
+			}
+		})
+	}
+}
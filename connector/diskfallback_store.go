@@ -0,0 +1,134 @@
+package connector
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/bborbe/teamvault-utils"
+	"github.com/pkg/errors"
+)
+
+// StoredEntry describes an entry of DiskFallback without its value. Err is
+// set if the entry can not be read, e.g. because of a wrong key.
+type StoredEntry struct {
+	Key      teamvault.Key
+	Kind     string
+	Fetched  time.Time
+	Revision string
+	Err      error
+}
+
+// Entry returns the stored entry of the key and kind.
+func (d *DiskFallback) Entry(key teamvault.Key, kind string) (*DiskEntry, error) {
+	return d.read(key, kind)
+}
+
+// Entries lists all stored entries ordered by key and kind.
+func (d *DiskFallback) Entries() ([]StoredEntry, error) {
+	var result []StoredEntry
+	err := d.walk(func(key teamvault.Key, kind string) error {
+		stored := StoredEntry{Key: key, Kind: kind}
+		entry, err := d.read(key, kind)
+		if err != nil {
+			stored.Err = err
+		} else {
+			stored.Fetched = entry.Fetched
+			stored.Revision = entry.Revision
+		}
+		result = append(result, stored)
+		return nil
+	})
+	return result, err
+}
+
+// Purge removes all entries of the key.
+func (d *DiskFallback) Purge(key teamvault.Key) error {
+	if key == "" {
+		return errors.New("key missing")
+	}
+	return errors.Wrapf(os.RemoveAll(d.cachedir(key)), "remove %s failed", d.cachedir(key))
+}
+
+// PurgeAll removes all entries. Salt and lock files are kept.
+func (d *DiskFallback) PurgeAll() error {
+	keys, err := d.keys()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := d.Purge(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prune removes entries fetched longer than maxAge ago or of unknown age
+// and returns their number. Entries that can not be read, e.g. encrypted
+// without the key configured, are kept and returned.
+func (d *DiskFallback) Prune(maxAge time.Duration) (int, []StoredEntry, error) {
+	var count int
+	var unreadable []StoredEntry
+	err := d.walk(func(key teamvault.Key, kind string) error {
+		entry, err := d.read(key, kind)
+		if err != nil {
+			unreadable = append(unreadable, StoredEntry{Key: key, Kind: kind, Err: err})
+			return nil
+		}
+		if !entry.Fetched.IsZero() && time.Since(entry.Fetched) <= maxAge {
+			return nil
+		}
+		if err := os.Remove(d.cachefile(key, kind)); err != nil {
+			return errors.Wrapf(err, "remove %s failed", d.cachefile(key, kind))
+		}
+		count++
+		// fails with exist as long as other kinds of the key are left
+		if err := os.Remove(d.cachedir(key)); err != nil && !os.IsExist(err) && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "remove %s failed", d.cachedir(key))
+		}
+		return nil
+	})
+	return count, unreadable, err
+}
+
+// keys lists the keys with entries.
+func (d *DiskFallback) keys() ([]teamvault.Key, error) {
+	infos, err := ioutil.ReadDir(d.dir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "read dir %s failed", d.dir())
+	}
+	var result []teamvault.Key
+	for _, info := range infos {
+		if info.IsDir() {
+			result = append(result, teamvault.Key(info.Name()))
+		}
+	}
+	return result, nil
+}
+
+// walk calls fn for every entry ordered by key and kind.
+func (d *DiskFallback) walk(fn func(key teamvault.Key, kind string) error) error {
+	keys, err := d.keys()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		infos, err := ioutil.ReadDir(d.cachedir(key))
+		if err != nil {
+			return errors.Wrapf(err, "read dir %s failed", d.cachedir(key))
+		}
+		for _, info := range infos {
+			if info.IsDir() {
+				continue
+			}
+			if err := fn(key, info.Name()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
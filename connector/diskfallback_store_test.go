@@ -0,0 +1,136 @@
+package connector_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/bborbe/assert"
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/connector"
+)
+
+func createStore(t *testing.T) (*connector.DiskFallback, teamvault.Key) {
+	memory := connector.NewMemory()
+	store := &connector.DiskFallback{Connector: memory, Dir: t.TempDir()}
+	key, err := memory.Create(context.Background(), teamvault.NewSecret{
+		ContentType: teamvault.ContentTypePassword,
+		Name:        "db",
+		User:        "admin",
+		Password:    "S3CR3T",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Password(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.User(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+	return store, key
+}
+
+func TestDiskFallbackEntries(t *testing.T) {
+	store, key := createStore(t)
+	entries, err := store.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(len(entries), Is(2)); err != nil {
+		t.Fatal(err)
+	}
+	for i, kind := range []string{"password", "user"} {
+		if err := AssertThat(entries[i].Key, Is(key)); err != nil {
+			t.Fatal(err)
+		}
+		if err := AssertThat(entries[i].Kind, Is(kind)); err != nil {
+			t.Fatal(err)
+		}
+		if err := AssertThat(entries[i].Revision == "", Is(false)); err != nil {
+			t.Fatal(err)
+		}
+		if err := AssertThat(entries[i].Err, NilValue()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDiskFallbackPurge(t *testing.T) {
+	store, key := createStore(t)
+	if err := ioutil.WriteFile(filepath.Join(store.Dir, ".salt"), []byte("salt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Purge(key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Entry(key, "password"); err == nil {
+		t.Fatal("error expected")
+	}
+	if err := store.PurgeAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(store.Dir, ".salt")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiskFallbackPrune(t *testing.T) {
+	store, key := createStore(t)
+	count, _, err := store.Prune(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(count, Is(0)); err != nil {
+		t.Fatal(err)
+	}
+	count, _, err = store.Prune(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(count, Is(2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(store.Dir, key.String())); !os.IsNotExist(err) {
+		t.Fatalf("directory of key removed expected, got %v", err)
+	}
+}
+
+func TestDiskFallbackPruneKeepsUnreadable(t *testing.T) {
+	store, key := createStore(t)
+	store.Key = newDiskKey(t, 1)
+	if _, err := store.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	store.Key = nil
+	count, unreadable, err := store.Prune(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(count, Is(0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(len(unreadable), Is(2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(store.Dir, key.String())); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiskFallbackPruneFailsToRemoveDirectory(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root removes the directory anyway")
+	}
+	store, _ := createStore(t)
+	if err := os.Chmod(store.Dir, 0500); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(store.Dir, 0700)
+	if _, _, err := store.Prune(0); err == nil {
+		t.Fatal("error expected")
+	}
+}
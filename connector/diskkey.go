@@ -0,0 +1,119 @@
+package connector
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// passphraseIterations of PBKDF2-SHA256 as recommended by OWASP.
+const passphraseIterations = 600000
+
+// diskKeyMagic starts every encrypted entry, entries without it are
+// plaintext written by earlier versions.
+var diskKeyMagic = []byte("\x00TVC1")
+
+// DiskKey encrypts the entries of DiskFallback with AES-256-GCM. The key
+// and kind of an entry are authenticated too, so moved, altered or
+// foreign entries fail to decrypt.
+type DiskKey struct {
+	aead cipher.AEAD
+}
+
+// NewDiskKey uses the 32 bytes as AES-256 key.
+func NewDiskKey(key []byte) (*DiskKey, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must have 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "create cipher failed")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "create gcm failed")
+	}
+	return &DiskKey{aead: aead}, nil
+}
+
+// ReadDiskKeyFile hashes the content of the keyfile, which must have at
+// least 32 bytes, e.g. created with "head -c 32 /dev/urandom".
+func ReadDiskKeyFile(path string) (*DiskKey, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read keyfile %s failed", path)
+	}
+	if len(content) < 32 {
+		return nil, fmt.Errorf("keyfile %s must have at least 32 bytes", path)
+	}
+	key := sha256.Sum256(content)
+	return NewDiskKey(key[:])
+}
+
+// DeriveDiskKey derives the key from the passphrase with PBKDF2.
+func DeriveDiskKey(passphrase string, salt []byte) (*DiskKey, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase is empty")
+	}
+	return NewDiskKey(pbkdf2.Key([]byte(passphrase), salt, passphraseIterations, 32, sha256.New))
+}
+
+// DiskKeySalt reads the salt for DeriveDiskKey stored in the directory and
+// creates it on first use.
+func DiskKeySalt(dir string) ([]byte, error) {
+	path := filepath.Join(dir, ".salt")
+	salt, err := ioutil.ReadFile(path)
+	if err == nil {
+		return salt, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "read %s failed", path)
+	}
+	salt = make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrap(err, "create salt failed")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrapf(err, "mkdir %s failed", dir)
+	}
+	if err := ioutil.WriteFile(path, salt, 0600); err != nil {
+		return nil, errors.Wrapf(err, "write %s failed", path)
+	}
+	return salt, nil
+}
+
+func (k *DiskKey) seal(name string, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "create nonce failed")
+	}
+	result := append([]byte{}, diskKeyMagic...)
+	result = append(result, nonce...)
+	return k.aead.Seal(result, nonce, plaintext, []byte(name)), nil
+}
+
+func (k *DiskKey) open(name string, content []byte) ([]byte, error) {
+	content = content[len(diskKeyMagic):]
+	if len(content) < k.aead.NonceSize() {
+		return nil, fmt.Errorf("entry %s is truncated", name)
+	}
+	nonce := content[:k.aead.NonceSize()]
+	plaintext, err := k.aead.Open(nil, nonce, content[k.aead.NonceSize():], []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("entry %s was altered or encrypted with another key", name)
+	}
+	return plaintext, nil
+}
+
+func encrypted(content []byte) bool {
+	return bytes.HasPrefix(content, diskKeyMagic)
+}
@@ -0,0 +1,189 @@
+package connector_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/bborbe/assert"
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/connector"
+)
+
+func newDiskKey(t *testing.T, seed byte) *connector.DiskKey {
+	key, err := connector.NewDiskKey(bytes.Repeat([]byte{seed}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// storeUnavailable stores the password on disk and lets reads of the
+// secret fail as if Teamvault were unavailable, so the next read is
+// served from disk.
+func storeUnavailable(t *testing.T, fallback *connector.DiskFallback, memory *connector.Memory) teamvault.Key {
+	key, err := memory.Create(context.Background(), teamvault.NewSecret{
+		ContentType: teamvault.ContentTypePassword,
+		Name:        "db",
+		Password:    "S3CR3T",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fallback.Password(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+	memory.Fail(key, fmt.Errorf("get %v failed: %w", key, teamvault.ErrServerUnavailable))
+	return key
+}
+
+func TestDiskFallbackEncrypts(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	memory := connector.NewMemory()
+	fallback := &connector.DiskFallback{Connector: memory, Key: newDiskKey(t, 1)}
+	key := storeUnavailable(t, fallback, memory)
+	content, err := ioutil.ReadFile(filepath.Join(connector.DefaultDiskFallbackDir(), key.String(), "password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(bytes.Contains(content, []byte("S3CR3T")), Is(false)); err != nil {
+		t.Fatal(err)
+	}
+	password, err := fallback.Password(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("S3CR3T"))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiskFallbackDetectsTampering(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	memory := connector.NewMemory()
+	fallback := &connector.DiskFallback{Connector: memory, Key: newDiskKey(t, 1)}
+	key := storeUnavailable(t, fallback, memory)
+	path := filepath.Join(connector.DefaultDiskFallbackDir(), key.String(), "password")
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content[len(content)-1] ^= 1
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fallback.Password(context.Background(), key); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestDiskFallbackDetectsForeignKey(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	memory := connector.NewMemory()
+	key := storeUnavailable(t, &connector.DiskFallback{Connector: memory, Key: newDiskKey(t, 1)}, memory)
+	if _, err := (&connector.DiskFallback{Connector: memory, Key: newDiskKey(t, 2)}).Password(context.Background(), key); err == nil {
+		t.Fatal("error expected")
+	}
+	if _, err := (&connector.DiskFallback{Connector: memory}).Password(context.Background(), key); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestDiskFallbackRefusesPlaintext(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	memory := connector.NewMemory()
+	key := storeUnavailable(t, &connector.DiskFallback{Connector: memory}, memory)
+	path := filepath.Join(connector.DefaultDiskFallbackDir(), key.String(), "password")
+	before, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fallback := &connector.DiskFallback{Connector: memory, Key: newDiskKey(t, 1)}
+	if _, err := fallback.Password(context.Background(), key); err == nil {
+		t.Fatal("error expected")
+	}
+	if _, err := fallback.Entries(); err != nil {
+		t.Fatal(err)
+	}
+	after, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(bytes.Equal(before, after), Is(true)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fallback.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	password, err := fallback.Password(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("S3CR3T"))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiskFallbackMigrate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := filepath.Join(connector.DefaultDiskFallbackDir(), "key123")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, kind := range []string{"user", "password"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, kind), []byte("plain"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fallback := &connector.DiskFallback{Key: newDiskKey(t, 1)}
+	for _, expected := range []int{2, 0} {
+		count, err := fallback.Migrate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := AssertThat(count, Is(expected)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDeriveDiskKey(t *testing.T) {
+	dir := t.TempDir()
+	salt, err := connector.DiskKeySalt(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := connector.DiskKeySalt(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(bytes.Equal(salt, again), Is(true)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := connector.DeriveDiskKey("passphrase", salt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := connector.DeriveDiskKey("", salt); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestReadDiskKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	if err := ioutil.WriteFile(path, []byte("short"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := connector.ReadDiskKeyFile(path); err == nil {
+		t.Fatal("error expected")
+	}
+	if err := ioutil.WriteFile(path, bytes.Repeat([]byte("k"), 32), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := connector.ReadDiskKeyFile(path); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,8 +1,11 @@
 package connector
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
+	"fmt"
+	"strconv"
 
 	"github.com/bborbe/teamvault-utils"
 )
@@ -15,29 +18,116 @@ func NewDummy() *Dummy {
 	return t
 }
 
-func (t *Dummy) Password(key teamvault.Key) (teamvault.Password, error) {
+func (t *Dummy) Password(ctx context.Context, key teamvault.Key) (teamvault.Password, error) {
 	h := sha256.New()
 	h.Write([]byte(key + "-password"))
 	result := base64.URLEncoding.EncodeToString(h.Sum(nil))
 	return teamvault.Password(result), nil
 }
 
-func (t *Dummy) User(key teamvault.Key) (teamvault.User, error) {
+func (t *Dummy) User(ctx context.Context, key teamvault.Key) (teamvault.User, error) {
 	return teamvault.User(key.String()), nil
 }
 
-func (t *Dummy) Url(key teamvault.Key) (teamvault.Url, error) {
+func (t *Dummy) Url(ctx context.Context, key teamvault.Key) (teamvault.Url, error) {
 	h := sha256.New()
 	h.Write([]byte(key + "-url"))
 	result := base64.URLEncoding.EncodeToString(h.Sum(nil))
 	return teamvault.Url(result), nil
 }
 
-func (t *Dummy) File(key teamvault.Key) (teamvault.File, error) {
+func (t *Dummy) File(ctx context.Context, key teamvault.Key) (teamvault.File, error) {
 	result := base64.URLEncoding.EncodeToString([]byte(key + "-file"))
 	return teamvault.File(result), nil
 }
 
-func (t *Dummy) Search(search string) ([]teamvault.Key, error) {
+// CreditCard returns a fake card with a valid Luhn checksum derived from the key.
+func (t *Dummy) CreditCard(ctx context.Context, key teamvault.Key) (*teamvault.CreditCard, error) {
+	h := sha256.New()
+	h.Write([]byte(key + "-creditcard"))
+	sum := h.Sum(nil)
+	digits := []byte{'4'}
+	for i := 0; i < 14; i++ {
+		digits = append(digits, '0'+sum[i]%10)
+	}
+	digits = append(digits, luhnCheckDigit(digits))
+	return &teamvault.CreditCard{
+		Holder:          key.String(),
+		Number:          string(digits),
+		ExpirationMonth: strconv.Itoa(int(sum[14]%12) + 1),
+		ExpirationYear:  strconv.Itoa(2030 + int(sum[15]%10)),
+		SecurityCode:    fmt.Sprintf("%03d", (int(sum[16])<<8|int(sum[17]))%1000),
+	}, nil
+}
+
+func luhnCheckDigit(digits []byte) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func (t *Dummy) Search(ctx context.Context, options teamvault.SearchOptions) ([]teamvault.Secret, error) {
 	return nil, nil
 }
+
+func (t *Dummy) Create(ctx context.Context, secret teamvault.NewSecret) (teamvault.Key, error) {
+	if err := secret.Validate(); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write([]byte(secret.Name + "-key"))
+	result := base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+	return teamvault.Key(result[:6]), nil
+}
+
+func (t *Dummy) Update(ctx context.Context, key teamvault.Key, data teamvault.SecretData) (teamvault.TeamvaultCurrentRevision, error) {
+	if err := data.Validate(); err != nil {
+		return "", err
+	}
+	return revisionUrl(dummyRevision(key)), nil
+}
+
+func (t *Dummy) Revisions(ctx context.Context, key teamvault.Key) ([]teamvault.Revision, error) {
+	return []teamvault.Revision{
+		{
+			Id: dummyRevision(key),
+		},
+	}, nil
+}
+
+func (t *Dummy) Secret(ctx context.Context, key teamvault.Key) (*teamvault.Secret, error) {
+	key, _ = key.Split()
+	user, _ := t.User(ctx, key)
+	url, _ := t.Url(ctx, key)
+	return &teamvault.Secret{
+		Key:             key,
+		Name:            teamvault.Name(key.String()),
+		ContentType:     teamvault.ContentTypePassword,
+		User:            user,
+		Url:             url,
+		Status:          teamvault.SecretStatusOk,
+		AccessPolicy:    teamvault.AccessPolicyEveryone,
+		CurrentRevision: revisionUrl(dummyRevision(key)),
+	}, nil
+}
+
+func dummyRevision(key teamvault.Key) teamvault.RevisionId {
+	key, _ = key.Split()
+	h := sha256.New()
+	h.Write([]byte(key + "-revision"))
+	result := base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+	return teamvault.RevisionId(result[:6])
+}
+
+func (t *Dummy) RequestAccess(ctx context.Context, key teamvault.Key, reason teamvault.Reason) error {
+	return nil
+}
@@ -1,6 +1,7 @@
 package connector_test
 
 import (
+	"context"
 	"testing"
 
 	. "github.com/bborbe/assert"
@@ -16,10 +17,18 @@ func TestDummyConnctorImplementsConnector(t *testing.T) {
 	}
 }
 
+func TestDummyConnctorImplementsWriter(t *testing.T) {
+	c := connector.NewDummy()
+	var i *teamvault.Writer
+	if err := AssertThat(c, Implements(i)); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestDummyUser(t *testing.T) {
 	key := teamvault.Key("key123")
 	du := connector.NewDummy()
-	user, err := du.User(key)
+	user, err := du.User(context.Background(), key)
 	if err := AssertThat(err, NilValue()); err != nil {
 		t.Fatal(err)
 	}
@@ -31,7 +40,7 @@ func TestDummyUser(t *testing.T) {
 func TestDummyPassword(t *testing.T) {
 	key := teamvault.Key("key123")
 	du := connector.NewDummy()
-	password, err := du.Password(key)
+	password, err := du.Password(context.Background(), key)
 	if err := AssertThat(err, NilValue()); err != nil {
 		t.Fatal(err)
 	}
@@ -43,7 +52,7 @@ func TestDummyPassword(t *testing.T) {
 func TestDummyURL(t *testing.T) {
 	key := teamvault.Key("key123")
 	du := connector.NewDummy()
-	url, err := du.Url(key)
+	url, err := du.Url(context.Background(), key)
 	if err := AssertThat(err, NilValue()); err != nil {
 		t.Fatal(err)
 	}
@@ -51,3 +60,43 @@ func TestDummyURL(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestDummyCreditCard(t *testing.T) {
+	key := teamvault.Key("key123")
+	du := connector.NewDummy()
+	creditCard, err := du.CreditCard(context.Background(), key)
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	again, err := du.CreditCard(context.Background(), key)
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(*again, Is(*creditCard)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(len(creditCard.Number), Is(16)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(luhnValid(creditCard.Number), Is(true)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(len(creditCard.SecurityCode), Is(3)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func luhnValid(number string) bool {
+	sum := 0
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if (len(number)-i)%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
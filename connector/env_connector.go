@@ -0,0 +1,137 @@
+package connector
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bborbe/teamvault-utils"
+)
+
+// DefaultEnvPattern names the variables like TEAMVAULT_vLVLbm_PASSWORD.
+// The key keeps its case, keys of Teamvault are case sensitive.
+const DefaultEnvPattern = "TEAMVAULT_{key}_{KIND}"
+
+// Env answers passwords, users, urls and files from environment variables
+// and asks the connector only if the variable is not set. Files are read
+// as plain content. With Strict the connector is never asked, unset
+// variables and all other calls fail with ErrNotFound.
+type Env struct {
+	Connector teamvault.Connector
+	// Pattern names the variables, {key} and {kind} are replaced by key
+	// and kind, {KEY} and {KIND} by them in upper case. With {KEY} keys
+	// differing only in case share a variable. DefaultEnvPattern if empty.
+	Pattern string
+	Strict  bool
+}
+
+func (e *Env) Password(ctx context.Context, key teamvault.Key) (teamvault.Password, error) {
+	if value, ok := e.lookup(key, "password"); ok {
+		return teamvault.Password(value), nil
+	}
+	if e.Strict {
+		return "", e.notFound("password", key)
+	}
+	return e.Connector.Password(ctx, key)
+}
+
+func (e *Env) User(ctx context.Context, key teamvault.Key) (teamvault.User, error) {
+	if value, ok := e.lookup(key, "user"); ok {
+		return teamvault.User(value), nil
+	}
+	if e.Strict {
+		return "", e.notFound("user", key)
+	}
+	return e.Connector.User(ctx, key)
+}
+
+func (e *Env) Url(ctx context.Context, key teamvault.Key) (teamvault.Url, error) {
+	if value, ok := e.lookup(key, "url"); ok {
+		return teamvault.Url(value), nil
+	}
+	if e.Strict {
+		return "", e.notFound("url", key)
+	}
+	return e.Connector.Url(ctx, key)
+}
+
+// File returns the content of the variable base64 encoded like Teamvault.
+func (e *Env) File(ctx context.Context, key teamvault.Key) (teamvault.File, error) {
+	if value, ok := e.lookup(key, "file"); ok {
+		return teamvault.File(base64.StdEncoding.EncodeToString([]byte(value))), nil
+	}
+	if e.Strict {
+		return "", e.notFound("file", key)
+	}
+	return e.Connector.File(ctx, key)
+}
+
+func (e *Env) CreditCard(ctx context.Context, key teamvault.Key) (*teamvault.CreditCard, error) {
+	if e.Strict {
+		return nil, e.notFound("creditcard", key)
+	}
+	return e.Connector.CreditCard(ctx, key)
+}
+
+func (e *Env) Secret(ctx context.Context, key teamvault.Key) (*teamvault.Secret, error) {
+	if e.Strict {
+		return nil, e.notFound("secret", key)
+	}
+	return e.Connector.Secret(ctx, key)
+}
+
+func (e *Env) Search(ctx context.Context, options teamvault.SearchOptions) ([]teamvault.Secret, error) {
+	if e.Strict {
+		return nil, fmt.Errorf("search is not available from the environment: %w", teamvault.ErrNotFound)
+	}
+	return e.Connector.Search(ctx, options)
+}
+
+func (e *Env) Revisions(ctx context.Context, key teamvault.Key) ([]teamvault.Revision, error) {
+	if e.Strict {
+		return nil, e.notFound("revisions", key)
+	}
+	return e.Connector.Revisions(ctx, key)
+}
+
+// Name returns the variable of the kind of the key. A pinned key uses the
+// variable of the key without revision.
+func (e *Env) Name(key teamvault.Key, kind string) string {
+	key, _ = key.Split()
+	name := sanitizeEnvName(key.String())
+	pattern := e.Pattern
+	if pattern == "" {
+		pattern = DefaultEnvPattern
+	}
+	return strings.NewReplacer(
+		"{key}", name,
+		"{KEY}", strings.ToUpper(name),
+		"{kind}", kind,
+		"{KIND}", strings.ToUpper(kind),
+	).Replace(pattern)
+}
+
+func (e *Env) lookup(key teamvault.Key, kind string) (string, bool) {
+	return os.LookupEnv(e.Name(key, kind))
+}
+
+func (e *Env) notFound(kind string, key teamvault.Key) error {
+	switch kind {
+	case "password", "user", "url", "file":
+		return fmt.Errorf("%s of %v not found, %s is not set: %w", kind, key, e.Name(key, kind), teamvault.ErrNotFound)
+	default:
+		return fmt.Errorf("%s of %v is not available from the environment: %w", kind, key, teamvault.ErrNotFound)
+	}
+}
+
+// sanitizeEnvName replaces all characters not allowed in variable names by "_".
+func sanitizeEnvName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
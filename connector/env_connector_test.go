@@ -0,0 +1,88 @@
+package connector_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/bborbe/assert"
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/connector"
+)
+
+func TestEnvImplementsConnector(t *testing.T) {
+	var i *teamvault.Connector
+	if err := AssertThat(&connector.Env{}, Implements(i)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	for _, c := range []struct {
+		pattern  string
+		key      teamvault.Key
+		expected string
+	}{
+		{"", "vLVLbm", "TEAMVAULT_vLVLbm_PASSWORD"},
+		{"", "vLVLbm@rKp1x5", "TEAMVAULT_vLVLbm_PASSWORD"},
+		{"", "a-b.c", "TEAMVAULT_a_b_c_PASSWORD"},
+		{"TV_{KEY}_{kind}", "vLVLbm", "TV_VLVLBM_password"},
+		{"TV_{key}_{kind}", "vLVLbm", "TV_vLVLbm_password"},
+	} {
+		env := &connector.Env{Pattern: c.pattern}
+		if err := AssertThat(env.Name(c.key, "password"), Is(c.expected)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEnvOverridesConnector(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("TEAMVAULT_key123_PASSWORD", "S3CR3T")
+	t.Setenv("TEAMVAULT_key123_FILE", "content")
+	env := &connector.Env{Connector: connector.NewDummy()}
+	password, err := env.Password(ctx, "key123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("S3CR3T"))); err != nil {
+		t.Fatal(err)
+	}
+	file, err := env.File(ctx, "key123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := file.Content()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(string(content), Is("content")); err != nil {
+		t.Fatal(err)
+	}
+	user, err := env.User(ctx, "key123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(user, Is(teamvault.User("key123"))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnvStrict(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("TEAMVAULT_key123_USER", "admin")
+	env := &connector.Env{Connector: connector.NewDummy(), Strict: true}
+	user, err := env.User(ctx, "key123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(user, Is(teamvault.User("admin"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Password(ctx, "key123"); !errors.Is(err, teamvault.ErrNotFound) {
+		t.Fatalf("not found expected, got %v", err)
+	}
+	if _, err := env.CreditCard(ctx, "key123"); !errors.Is(err, teamvault.ErrNotFound) {
+		t.Fatalf("not found expected, got %v", err)
+	}
+}
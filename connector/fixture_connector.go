@@ -0,0 +1,181 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/bborbe/teamvault-utils"
+	"gopkg.in/yaml.v2"
+)
+
+// FixtureSecret is a secret of a fixture file.
+type FixtureSecret struct {
+	// Name defaults to the key.
+	Name        teamvault.Name        `json:"name" yaml:"name"`
+	Description teamvault.Description `json:"description" yaml:"description"`
+	// ContentType defaults to file with file, cc with credit_card and
+	// password otherwise.
+	ContentType  teamvault.ContentType  `json:"content_type" yaml:"content_type"`
+	User         teamvault.User         `json:"username" yaml:"username"`
+	Url          teamvault.Url          `json:"url" yaml:"url"`
+	Filename     teamvault.Filename     `json:"filename" yaml:"filename"`
+	AccessPolicy teamvault.AccessPolicy `json:"access_policy" yaml:"access_policy"`
+	Password     teamvault.Password     `json:"password" yaml:"password"`
+	// File is base64 encoded like Teamvault returns it.
+	File teamvault.File `json:"file" yaml:"file"`
+	// FileContent is the plain content of the file, used if File is empty.
+	FileContent string                `json:"file_content" yaml:"file_content"`
+	CreditCard  *teamvault.CreditCard `json:"credit_card" yaml:"credit_card"`
+}
+
+func (f FixtureSecret) newSecret(key teamvault.Key) teamvault.NewSecret {
+	result := teamvault.NewSecret{
+		ContentType:  f.ContentType,
+		Name:         f.Name,
+		Description:  f.Description,
+		User:         f.User,
+		Url:          f.Url,
+		Filename:     f.Filename,
+		AccessPolicy: f.AccessPolicy,
+		Password:     f.Password,
+		File:         f.File,
+		CreditCard:   f.CreditCard,
+	}
+	if result.Name == "" {
+		result.Name = teamvault.Name(key.String())
+	}
+	if result.File == "" && f.FileContent != "" {
+		result.File = teamvault.File(base64.StdEncoding.EncodeToString([]byte(f.FileContent)))
+	}
+	if result.ContentType == "" {
+		switch {
+		case result.File != "":
+			result.ContentType = teamvault.ContentTypeFile
+		case result.CreditCard != nil:
+			result.ContentType = teamvault.ContentTypeCreditCard
+		default:
+			result.ContentType = teamvault.ContentTypePassword
+		}
+	}
+	return result
+}
+
+// Fixture serves the secrets of a fixture file, e.g. realistic values for
+// staging. Unknown keys are answered by Fallback or fail with ErrNotFound
+// without it.
+type Fixture struct {
+	*Memory
+	Fallback teamvault.Connector
+}
+
+// ParseFixture reads the secrets by key from a JSON object or a YAML
+// mapping.
+func ParseFixture(content []byte, fallback teamvault.Connector) (*Fixture, error) {
+	var secrets map[teamvault.Key]FixtureSecret
+	unmarshal := json.Unmarshal
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] != '{' && trimmed[0] != '[' {
+		unmarshal = yaml.Unmarshal
+	}
+	if err := unmarshal(content, &secrets); err != nil {
+		return nil, fmt.Errorf("parse fixture failed: %v", err)
+	}
+	keys := make([]teamvault.Key, 0, len(secrets))
+	for key := range secrets {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	f := &Fixture{
+		Memory:   NewMemory(),
+		Fallback: fallback,
+	}
+	for _, key := range keys {
+		if err := f.Put(key, secrets[key].newSecret(key)); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// ReadFixture reads the fixture file.
+func ReadFixture(path string, fallback teamvault.Connector) (*Fixture, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read fixture %s failed: %v", path, err)
+	}
+	return ParseFixture(content, fallback)
+}
+
+func (f *Fixture) Password(ctx context.Context, key teamvault.Key) (teamvault.Password, error) {
+	result, err := f.Memory.Password(ctx, key)
+	if f.unknown(err) {
+		return f.Fallback.Password(ctx, key)
+	}
+	return result, err
+}
+
+func (f *Fixture) User(ctx context.Context, key teamvault.Key) (teamvault.User, error) {
+	result, err := f.Memory.User(ctx, key)
+	if f.unknown(err) {
+		return f.Fallback.User(ctx, key)
+	}
+	return result, err
+}
+
+func (f *Fixture) Url(ctx context.Context, key teamvault.Key) (teamvault.Url, error) {
+	result, err := f.Memory.Url(ctx, key)
+	if f.unknown(err) {
+		return f.Fallback.Url(ctx, key)
+	}
+	return result, err
+}
+
+func (f *Fixture) File(ctx context.Context, key teamvault.Key) (teamvault.File, error) {
+	result, err := f.Memory.File(ctx, key)
+	if f.unknown(err) {
+		return f.Fallback.File(ctx, key)
+	}
+	return result, err
+}
+
+func (f *Fixture) CreditCard(ctx context.Context, key teamvault.Key) (*teamvault.CreditCard, error) {
+	result, err := f.Memory.CreditCard(ctx, key)
+	if f.unknown(err) {
+		// secrets of the fixture of other content types are no credit cards
+		if plain, _ := key.Split(); f.known(plain) {
+			return nil, err
+		}
+		return f.Fallback.CreditCard(ctx, key)
+	}
+	return result, err
+}
+
+func (f *Fixture) Secret(ctx context.Context, key teamvault.Key) (*teamvault.Secret, error) {
+	result, err := f.Memory.Secret(ctx, key)
+	if f.unknown(err) {
+		return f.Fallback.Secret(ctx, key)
+	}
+	return result, err
+}
+
+func (f *Fixture) Revisions(ctx context.Context, key teamvault.Key) ([]teamvault.Revision, error) {
+	result, err := f.Memory.Revisions(ctx, key)
+	if f.unknown(err) {
+		return f.Fallback.Revisions(ctx, key)
+	}
+	return result, err
+}
+
+func (f *Fixture) unknown(err error) bool {
+	return f.Fallback != nil && errors.Is(err, teamvault.ErrNotFound)
+}
+
+func (f *Fixture) known(key teamvault.Key) bool {
+	_, err := f.Memory.get(key)
+	return err == nil
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package connector
+
+import (
+	"fmt"
+	"os"
+)
+
+func lockFile(file *os.File, wait bool) error {
+	return fmt.Errorf("lock files are not supported on this platform")
+}
+
+func unlockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,237 @@
+package connector
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bborbe/teamvault-utils"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// LimitConfig throttles the requests of Remote. Zero values disable a limit.
+type LimitConfig struct {
+	// RequestsPerSecond is the rate requests are started with.
+	RequestsPerSecond float64
+	// Burst is the number of requests started at once before the rate applies.
+	Burst int
+	// MaxConcurrent is the number of requests in flight.
+	MaxConcurrent int
+	// LockFile lets all processes using the same file share the limits.
+	LockFile string
+}
+
+// NewLimitConfig reads the limits from the Teamvault config.
+func NewLimitConfig(config *teamvault.TeamvaultConfig) LimitConfig {
+	result := LimitConfig{
+		RequestsPerSecond: config.RateLimit,
+		Burst:             config.RateLimitBurst,
+		MaxConcurrent:     config.MaxConcurrentRequests,
+	}
+	if config.RateLimitLock {
+		result.LockFile = DefaultLockFile()
+	}
+	return result
+}
+
+// DefaultLockFile is located in the cache directory.
+func DefaultLockFile() string {
+	return filepath.Join(DefaultDiskFallbackDir(), ".ratelimit.lock")
+}
+
+// Limiter limits rate and concurrency of requests. It is safe for
+// concurrent use and meant to be shared by all goroutines of a process.
+type Limiter struct {
+	config    LimitConfig
+	semaphore chan struct{}
+
+	mux    sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func NewLimiter(config LimitConfig) *Limiter {
+	l := &Limiter{
+		config: config,
+		tokens: float64(burst(config)),
+	}
+	if config.MaxConcurrent > 0 {
+		l.semaphore = make(chan struct{}, config.MaxConcurrent)
+	}
+	return l
+}
+
+func burst(config LimitConfig) int {
+	if config.Burst > 0 {
+		return config.Burst
+	}
+	return 1
+}
+
+// acquire blocks until a request may start. The returned func must be
+// called once the request is finished.
+func (l *Limiter) acquire(ctx context.Context) (func(), error) {
+	var releases []func()
+	release := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+	if l.semaphore != nil {
+		select {
+		case l.semaphore <- struct{}{}:
+			releases = append(releases, func() { <-l.semaphore })
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if l.config.LockFile != "" && l.config.MaxConcurrent > 0 {
+		unlock, err := acquireSlot(ctx, l.config.LockFile, l.config.MaxConcurrent)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		releases = append(releases, unlock)
+	}
+	if err := l.waitRate(ctx); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+func (l *Limiter) waitRate(ctx context.Context) error {
+	if l.config.RequestsPerSecond <= 0 {
+		return nil
+	}
+	if err := sleep(ctx, l.reserve(time.Now())); err != nil {
+		return err
+	}
+	if l.config.LockFile == "" {
+		return nil
+	}
+	wait, err := reserveShared(l.config.LockFile, l.interval(), time.Now())
+	if err != nil {
+		return err
+	}
+	return sleep(ctx, wait)
+}
+
+// reserve takes a token from the bucket and returns how long to wait for it.
+func (l *Limiter) reserve(now time.Time) time.Duration {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if !l.last.IsZero() {
+		l.tokens = math.Min(float64(burst(l.config)), l.tokens+now.Sub(l.last).Seconds()*l.config.RequestsPerSecond)
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.config.RequestsPerSecond * float64(time.Second))
+}
+
+func (l *Limiter) interval() time.Duration {
+	return time.Duration(float64(time.Second) / l.config.RequestsPerSecond)
+}
+
+// reserveShared spaces the requests of all processes by the interval. The
+// lock file holds the time the next request may start.
+func reserveShared(path string, interval time.Duration, now time.Time) (time.Duration, error) {
+	file, err := openLockFile(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	if err := lockFile(file, true); err != nil {
+		return 0, errors.Wrapf(err, "lock %s failed", path)
+	}
+	defer unlockFile(file)
+	var next int64
+	if err := binary.Read(file, binary.BigEndian, &next); err != nil && err != io.EOF {
+		glog.V(2).Infof("read %s failed: %v", path, err)
+	}
+	start := now
+	if t := time.Unix(0, next); t.After(start) {
+		start = t
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if err := binary.Write(file, binary.BigEndian, start.Add(interval).UnixNano()); err != nil {
+		return 0, errors.Wrapf(err, "write %s failed", path)
+	}
+	return start.Sub(now), nil
+}
+
+// acquireSlot takes one of the slots shared by all processes.
+func acquireSlot(ctx context.Context, path string, slots int) (func(), error) {
+	for {
+		for i := 0; i < slots; i++ {
+			file, err := openLockFile(path + ".slot" + strconv.Itoa(i))
+			if err != nil {
+				return nil, err
+			}
+			if lockFile(file, false) == nil {
+				return func() {
+					unlockFile(file)
+					file.Close()
+				}, nil
+			}
+			file.Close()
+		}
+		if err := sleep(ctx, 50*time.Millisecond); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func openLockFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, errors.Wrapf(err, "mkdir %s failed", filepath.Dir(path))
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open %s failed", path)
+	}
+	return file, nil
+}
+
+// limit runs the request once the limiter allows it and frees the limiter
+// when the body of the response is closed.
+func limit(limiter *Limiter, executeRequest func(req *http.Request) (resp *http.Response, err error)) func(req *http.Request) (resp *http.Response, err error) {
+	return func(req *http.Request) (*http.Response, error) {
+		release, err := limiter.acquire(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		resp, err := executeRequest(req)
+		if err != nil || resp == nil || resp.Body == nil {
+			release()
+			return resp, err
+		}
+		resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+		return resp, nil
+	}
+}
+
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseBody) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
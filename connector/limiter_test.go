@@ -0,0 +1,134 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/bborbe/assert"
+	"github.com/bborbe/io/reader_nop_close"
+)
+
+func TestLimiterReserve(t *testing.T) {
+	limiter := NewLimiter(LimitConfig{RequestsPerSecond: 10, Burst: 2})
+	now := time.Now()
+	var tests = []struct {
+		now      time.Time
+		expected time.Duration
+	}{
+		{now, 0},
+		{now, 0},
+		{now, 100 * time.Millisecond},
+		{now, 200 * time.Millisecond},
+		{now.Add(time.Second), 0},
+	}
+	for _, tt := range tests {
+		if err := AssertThat(limiter.reserve(tt.now), Is(tt.expected)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLimitConcurrency(t *testing.T) {
+	var mux sync.Mutex
+	current, max := 0, 0
+	execute := limit(NewLimiter(LimitConfig{MaxConcurrent: 2}), func(req *http.Request) (*http.Response, error) {
+		mux.Lock()
+		current++
+		if current > max {
+			max = current
+		}
+		mux.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		return &http.Response{
+			StatusCode: 200,
+			Body:       reader_nop_close.New(bytes.NewBufferString(`{}`)),
+		}, nil
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodGet, "http://teamvault.example.com", nil)
+			resp, err := execute(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mux.Lock()
+			current--
+			mux.Unlock()
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	if err := AssertThat(max, Is(2)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLimitStopsWithContext(t *testing.T) {
+	limiter := NewLimiter(LimitConfig{MaxConcurrent: 1})
+	release, err := limiter.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = limiter.acquire(ctx)
+	if err := AssertThat(err, Is(context.Canceled)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReserveShared(t *testing.T) {
+	dir, err := ioutil.TempDir("", "limiter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ratelimit.lock")
+	now := time.Now()
+	for i, expected := range []time.Duration{0, time.Second, 2 * time.Second} {
+		wait, err := reserveShared(path, time.Second, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := AssertThat(wait, Is(expected)); err != nil {
+			t.Fatalf("reservation %d: %v", i, err)
+		}
+	}
+}
+
+func TestAcquireSlot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "limiter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ratelimit.lock")
+	release, err := acquireSlot(context.Background(), path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = acquireSlot(ctx, path, 1)
+	if err := AssertThat(err, Is(context.DeadlineExceeded)); err != nil {
+		t.Fatal(err)
+	}
+	release()
+	release, err = acquireSlot(context.Background(), path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
@@ -0,0 +1,94 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// memo remembers successful results by key. Concurrent callers asking for
+// the same key wait for the first call instead of issuing their own.
+// Results are kept forever unless ttl is set.
+type memo struct {
+	mux   sync.Mutex
+	calls map[string]*memoCall
+	ttl   time.Duration
+}
+
+type memoCall struct {
+	done    chan struct{}
+	value   interface{}
+	err     error
+	expires time.Time
+}
+
+func (m *memo) do(ctx context.Context, key string, fn func() (interface{}, error)) (interface{}, error) {
+	return m.call(ctx, key, fn, true)
+}
+
+// share only de-duplicates concurrent calls, the result is not kept.
+func (m *memo) share(ctx context.Context, key string, fn func() (interface{}, error)) (interface{}, error) {
+	return m.call(ctx, key, fn, false)
+}
+
+func (m *memo) call(ctx context.Context, key string, fn func() (interface{}, error), keep bool) (interface{}, error) {
+	m.mux.Lock()
+	if m.calls == nil {
+		m.calls = make(map[string]*memoCall)
+	}
+	call, ok := m.calls[key]
+	if ok && call.expired(time.Now()) {
+		delete(m.calls, key)
+		ok = false
+	}
+	if ok {
+		m.mux.Unlock()
+		select {
+		case <-call.done:
+			if isContextError(call.err) && ctx.Err() == nil {
+				// the first caller gave up, try again on our own behalf
+				return m.call(ctx, key, fn, keep)
+			}
+			return call.value, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call = &memoCall{done: make(chan struct{})}
+	m.calls[key] = call
+	m.mux.Unlock()
+
+	call.value, call.err = fn()
+	m.mux.Lock()
+	if call.err != nil || !keep {
+		if m.calls[key] == call {
+			delete(m.calls, key)
+		}
+	} else if m.ttl > 0 {
+		call.expires = time.Now().Add(m.ttl)
+	}
+	m.mux.Unlock()
+	close(call.done)
+	return call.value, call.err
+}
+
+// expired reports whether the finished call is older than the ttl.
+func (c *memoCall) expired(now time.Time) bool {
+	select {
+	case <-c.done:
+		return !c.expires.IsZero() && now.After(c.expires)
+	default:
+		return false
+	}
+}
+
+func (m *memo) forget(key string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	delete(m.calls, key)
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,112 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/bborbe/assert"
+)
+
+func TestMemoRemembersValue(t *testing.T) {
+	var m memo
+	var calls int32
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return "value", nil
+	}
+	for i := 0; i < 3; i++ {
+		value, err := m.do(context.Background(), "key", fn)
+		if err := AssertThat(err, NilValue()); err != nil {
+			t.Fatal(err)
+		}
+		if err := AssertThat(value, Is("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := AssertThat(atomic.LoadInt32(&calls), Is(int32(1))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoForgetsErrors(t *testing.T) {
+	var m memo
+	var calls int32
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, fmt.Errorf("failed")
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := m.do(context.Background(), "key", fn); err == nil {
+			t.Fatal("error expected")
+		}
+	}
+	if err := AssertThat(atomic.LoadInt32(&calls), Is(int32(3))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoSharesConcurrentCalls(t *testing.T) {
+	var m memo
+	var calls int32
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "value", nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.do(context.Background(), "key", fn); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	close(release)
+	wg.Wait()
+	if err := AssertThat(atomic.LoadInt32(&calls), Is(int32(1))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoExpiresAfterTTL(t *testing.T) {
+	m := memo{ttl: time.Millisecond}
+	var calls int32
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return "value", nil
+	}
+	if _, err := m.do(context.Background(), "key", fn); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := m.do(context.Background(), "key", fn); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(atomic.LoadInt32(&calls), Is(int32(2))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoShareKeepsNothing(t *testing.T) {
+	var m memo
+	var calls int32
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return "value", nil
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := m.share(context.Background(), "key", fn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := AssertThat(atomic.LoadInt32(&calls), Is(int32(3))); err != nil {
+		t.Fatal(err)
+	}
+}
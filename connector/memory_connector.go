@@ -0,0 +1,308 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bborbe/teamvault-utils"
+)
+
+// Memory keeps secrets in memory. It is meant as fake in tests.
+type Memory struct {
+	mux      sync.Mutex
+	secrets  map[teamvault.Key]*memorySecret
+	keys     []teamvault.Key
+	failures map[teamvault.Key]error
+}
+
+type memorySecret struct {
+	secret         teamvault.NewSecret
+	revisions      []memoryRevision
+	restricted     bool
+	accessRequests []teamvault.Reason
+}
+
+type memoryRevision struct {
+	id      teamvault.RevisionId
+	created time.Time
+	data    teamvault.SecretData
+}
+
+// data returns the revision pinned in the key or the current revision.
+func (m *memorySecret) data(revision teamvault.RevisionId) (teamvault.SecretData, error) {
+	if revision == "" {
+		return m.revisions[len(m.revisions)-1].data, nil
+	}
+	for _, r := range m.revisions {
+		if r.id == revision {
+			return r.data, nil
+		}
+	}
+	return teamvault.SecretData{}, fmt.Errorf("revision %v %w", revision, teamvault.ErrNotFound)
+}
+
+func (m *memorySecret) add(key teamvault.Key, data teamvault.SecretData) teamvault.RevisionId {
+	id := teamvault.RevisionId(fmt.Sprintf("%sr%d", key, len(m.revisions)+1))
+	m.revisions = append(m.revisions, memoryRevision{
+		id:      id,
+		created: time.Now(),
+		data:    data,
+	})
+	return id
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		secrets: make(map[teamvault.Key]*memorySecret),
+	}
+}
+
+func (m *Memory) Create(ctx context.Context, secret teamvault.NewSecret) (teamvault.Key, error) {
+	if err := secret.Validate(); err != nil {
+		return "", err
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	key := teamvault.Key(fmt.Sprintf("key%d", len(m.keys)+1))
+	m.secrets[key] = &memorySecret{
+		secret: secret,
+	}
+	m.secrets[key].add(key, secret.Data())
+	m.keys = append(m.keys, key)
+	return key, nil
+}
+
+// Put stores the secret under the key, replacing an existing one.
+func (m *Memory) Put(key teamvault.Key, secret teamvault.NewSecret) error {
+	if err := secret.Validate(); err != nil {
+		return fmt.Errorf("secret %v invalid: %v", key, err)
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if _, ok := m.secrets[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.secrets[key] = &memorySecret{
+		secret: secret,
+	}
+	m.secrets[key].add(key, secret.Data())
+	return nil
+}
+
+func (m *Memory) Update(ctx context.Context, key teamvault.Key, data teamvault.SecretData) (teamvault.TeamvaultCurrentRevision, error) {
+	if err := data.Validate(); err != nil {
+		return "", err
+	}
+	key, _ = key.Split()
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	secret, ok := m.secrets[key]
+	if !ok {
+		return "", fmt.Errorf("secret %v %w", key, teamvault.ErrNotFound)
+	}
+	id := secret.add(key, data)
+	return revisionUrl(id), nil
+}
+
+func (m *Memory) Password(ctx context.Context, key teamvault.Key) (teamvault.Password, error) {
+	data, err := m.data(key)
+	if err != nil {
+		return "", err
+	}
+	return data.Password, nil
+}
+
+func (m *Memory) User(ctx context.Context, key teamvault.Key) (teamvault.User, error) {
+	key, _ = key.Split()
+	secret, err := m.get(key)
+	if err != nil {
+		return "", err
+	}
+	return secret.secret.User, nil
+}
+
+func (m *Memory) Url(ctx context.Context, key teamvault.Key) (teamvault.Url, error) {
+	key, _ = key.Split()
+	secret, err := m.get(key)
+	if err != nil {
+		return "", err
+	}
+	return secret.secret.Url, nil
+}
+
+func (m *Memory) File(ctx context.Context, key teamvault.Key) (teamvault.File, error) {
+	data, err := m.data(key)
+	if err != nil {
+		return "", err
+	}
+	return data.File, nil
+}
+
+func (m *Memory) CreditCard(ctx context.Context, key teamvault.Key) (*teamvault.CreditCard, error) {
+	data, err := m.data(key)
+	if err != nil {
+		return nil, err
+	}
+	if data.CreditCard == nil {
+		return nil, fmt.Errorf("secret %v is no credit card: %w", key, teamvault.ErrNotFound)
+	}
+	result := *data.CreditCard
+	return &result, nil
+}
+
+func (m *Memory) Revisions(ctx context.Context, key teamvault.Key) ([]teamvault.Revision, error) {
+	key, _ = key.Split()
+	secret, err := m.get(key)
+	if err != nil {
+		return nil, err
+	}
+	var result []teamvault.Revision
+	for _, r := range secret.revisions {
+		result = append(result, teamvault.Revision{
+			Id:      r.id,
+			Created: r.created,
+		})
+	}
+	return result, nil
+}
+
+func (m *Memory) Secret(ctx context.Context, key teamvault.Key) (*teamvault.Secret, error) {
+	key, _ = key.Split()
+	secret, err := m.get(key)
+	if err != nil {
+		return nil, err
+	}
+	current := secret.revisions[len(secret.revisions)-1]
+	accessPolicy := secret.secret.AccessPolicy
+	if accessPolicy == "" {
+		accessPolicy = teamvault.AccessPolicyRequest
+	}
+	return &teamvault.Secret{
+		Key:             key,
+		Name:            secret.secret.Name,
+		Description:     secret.secret.Description,
+		ContentType:     secret.secret.ContentType,
+		Filename:        secret.secret.Filename,
+		User:            secret.secret.User,
+		Url:             secret.secret.Url,
+		Status:          teamvault.SecretStatusOk,
+		AccessPolicy:    accessPolicy,
+		CurrentRevision: revisionUrl(current.id),
+		Created:         secret.revisions[0].created,
+	}, nil
+}
+
+func (m *Memory) Search(ctx context.Context, options teamvault.SearchOptions) ([]teamvault.Secret, error) {
+	m.mux.Lock()
+	keys := make([]teamvault.Key, len(m.keys))
+	copy(keys, m.keys)
+	m.mux.Unlock()
+	var result []teamvault.Secret
+	for _, key := range keys {
+		if options.Full(result) {
+			break
+		}
+		secret, err := m.Secret(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.Contains(strings.ToLower(secret.Name.String()), strings.ToLower(options.Name)) {
+			continue
+		}
+		if options.Matches(*secret) {
+			result = append(result, *secret)
+		}
+	}
+	return result, nil
+}
+
+// Restrict denies reading the data of the secret until Grant is called.
+func (m *Memory) Restrict(key teamvault.Key) error {
+	return m.restrict(key, true)
+}
+
+// Grant allows reading the data of a restricted secret again.
+func (m *Memory) Grant(key teamvault.Key) error {
+	return m.restrict(key, false)
+}
+
+func (m *Memory) restrict(key teamvault.Key, restricted bool) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	secret, ok := m.secrets[key]
+	if !ok {
+		return fmt.Errorf("secret %v %w", key, teamvault.ErrNotFound)
+	}
+	secret.restricted = restricted
+	return nil
+}
+
+// Fail lets all reads of the secret fail with the error, nil reads it again.
+func (m *Memory) Fail(key teamvault.Key, err error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.failures == nil {
+		m.failures = make(map[teamvault.Key]error)
+	}
+	if err == nil {
+		delete(m.failures, key)
+		return
+	}
+	m.failures[key] = err
+}
+
+func (m *Memory) RequestAccess(ctx context.Context, key teamvault.Key, reason teamvault.Reason) error {
+	key, _ = key.Split()
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	secret, ok := m.secrets[key]
+	if !ok {
+		return fmt.Errorf("secret %v %w", key, teamvault.ErrNotFound)
+	}
+	secret.accessRequests = append(secret.accessRequests, reason)
+	return nil
+}
+
+// AccessRequests returns the reasons of all access requests filed for the secret.
+func (m *Memory) AccessRequests(key teamvault.Key) []teamvault.Reason {
+	secret, err := m.get(key)
+	if err != nil {
+		return nil
+	}
+	result := make([]teamvault.Reason, len(secret.accessRequests))
+	copy(result, secret.accessRequests)
+	return result
+}
+
+func (m *Memory) get(key teamvault.Key) (memorySecret, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if err, ok := m.failures[key]; ok {
+		return memorySecret{}, err
+	}
+	secret, ok := m.secrets[key]
+	if !ok {
+		return memorySecret{}, fmt.Errorf("secret %v %w", key, teamvault.ErrNotFound)
+	}
+	return *secret, nil
+}
+
+func (m *Memory) data(key teamvault.Key) (teamvault.SecretData, error) {
+	key, revision := key.Split()
+	secret, err := m.get(key)
+	if err != nil {
+		return teamvault.SecretData{}, err
+	}
+	if secret.restricted {
+		return teamvault.SecretData{}, fmt.Errorf("secret %v %w", key, teamvault.ErrAccessRequestRequired)
+	}
+	return secret.data(revision)
+}
+
+// revisionUrl returns the api path of the revision as fakes report it.
+func revisionUrl(id teamvault.RevisionId) teamvault.TeamvaultCurrentRevision {
+	return teamvault.TeamvaultCurrentRevision(fmt.Sprintf("/api/secret-revisions/%s/", id))
+}
@@ -0,0 +1,176 @@
+package connector_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/bborbe/assert"
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/connector"
+)
+
+func TestMemoryConnctorImplementsConnector(t *testing.T) {
+	c := connector.NewMemory()
+	var i *teamvault.Connector
+	if err := AssertThat(c, Implements(i)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryConnctorImplementsWriter(t *testing.T) {
+	c := connector.NewMemory()
+	var i *teamvault.Writer
+	if err := AssertThat(c, Implements(i)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryCreate(t *testing.T) {
+	ctx := context.Background()
+	m := connector.NewMemory()
+	key, err := m.Create(ctx, teamvault.NewSecret{
+		ContentType: teamvault.ContentTypePassword,
+		Name:        "My Service",
+		User:        "admin",
+		Url:         "https://example.com",
+		Password:    "S3CR3T",
+	})
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	password, err := m.Password(ctx, key)
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("S3CR3T"))); err != nil {
+		t.Fatal(err)
+	}
+	user, err := m.User(ctx, key)
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(user, Is(teamvault.User("admin"))); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := m.Search(ctx, teamvault.SearchOptions{Name: "service"})
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(len(matches), Is(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(matches[0].Key, Is(key)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryCreateInvalid(t *testing.T) {
+	m := connector.NewMemory()
+	_, err := m.Create(context.Background(), teamvault.NewSecret{
+		ContentType: teamvault.ContentTypeFile,
+		Name:        "My Service",
+	})
+	if err := AssertThat(err, NotNilValue()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryUnknownKey(t *testing.T) {
+	m := connector.NewMemory()
+	_, err := m.Password(context.Background(), "unknown")
+	if err := AssertThat(err, NotNilValue()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryUpdate(t *testing.T) {
+	ctx := context.Background()
+	m := connector.NewMemory()
+	key, err := m.Create(ctx, teamvault.NewSecret{
+		ContentType: teamvault.ContentTypePassword,
+		Name:        "My Service",
+		Password:    "old",
+	})
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	revision, err := m.Update(ctx, key, teamvault.SecretData{Password: "new"})
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(revision.String(), Is("/api/secret-revisions/key1r2/")); err != nil {
+		t.Fatal(err)
+	}
+	password, err := m.Password(ctx, key)
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("new"))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryPinnedRevision(t *testing.T) {
+	ctx := context.Background()
+	m := connector.NewMemory()
+	key, err := m.Create(ctx, teamvault.NewSecret{
+		ContentType: teamvault.ContentTypePassword,
+		Name:        "My Service",
+		Password:    "old",
+	})
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Update(ctx, key, teamvault.SecretData{Password: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	revisions, err := m.Revisions(ctx, key)
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(len(revisions), Is(2)); err != nil {
+		t.Fatal(err)
+	}
+	password, err := m.Password(ctx, key.WithRevision(revisions[0].Id))
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("old"))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemorySearchOptions(t *testing.T) {
+	ctx := context.Background()
+	m := connector.NewMemory()
+	for _, secret := range []teamvault.NewSecret{
+		{ContentType: teamvault.ContentTypePassword, Name: "db", Password: "S3CR3T"},
+		{ContentType: teamvault.ContentTypePassword, Name: "db replica", Password: "S3CR3T"},
+		{ContentType: teamvault.ContentTypeFile, Name: "db cert", File: "Y2VydA=="},
+	} {
+		if _, err := m.Create(ctx, secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var tests = []struct {
+		name     string
+		options  teamvault.SearchOptions
+		expected int
+	}{
+		{"all", teamvault.SearchOptions{Name: "db"}, 3},
+		{"exact", teamvault.SearchOptions{Name: "db", ExactName: true}, 1},
+		{"content type", teamvault.SearchOptions{Name: "db", ContentType: teamvault.ContentTypeFile}, 1},
+		{"limit", teamvault.SearchOptions{Name: "db", Limit: 2}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := m.Search(ctx, tt.options)
+			if err := AssertThat(err, NilValue()); err != nil {
+				t.Fatal(err)
+			}
+			if err := AssertThat(len(matches), Is(tt.expected)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package connector
+
+import (
+	"context"
+	"time"
+
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/metrics"
+)
+
+// Metrics records count, latency and errors by type of all calls to the connector.
+type Metrics struct {
+	Connector teamvault.Connector
+	Registry  *metrics.Registry
+}
+
+func (m *Metrics) Password(ctx context.Context, key teamvault.Key) (teamvault.Password, error) {
+	start := time.Now()
+	result, err := m.Connector.Password(ctx, key)
+	m.observe("password", start, err)
+	return result, err
+}
+
+func (m *Metrics) User(ctx context.Context, key teamvault.Key) (teamvault.User, error) {
+	start := time.Now()
+	result, err := m.Connector.User(ctx, key)
+	m.observe("user", start, err)
+	return result, err
+}
+
+func (m *Metrics) Url(ctx context.Context, key teamvault.Key) (teamvault.Url, error) {
+	start := time.Now()
+	result, err := m.Connector.Url(ctx, key)
+	m.observe("url", start, err)
+	return result, err
+}
+
+func (m *Metrics) File(ctx context.Context, key teamvault.Key) (teamvault.File, error) {
+	start := time.Now()
+	result, err := m.Connector.File(ctx, key)
+	m.observe("file", start, err)
+	return result, err
+}
+
+func (m *Metrics) CreditCard(ctx context.Context, key teamvault.Key) (*teamvault.CreditCard, error) {
+	start := time.Now()
+	result, err := m.Connector.CreditCard(ctx, key)
+	m.observe("creditcard", start, err)
+	return result, err
+}
+
+func (m *Metrics) Search(ctx context.Context, options teamvault.SearchOptions) ([]teamvault.Secret, error) {
+	start := time.Now()
+	result, err := m.Connector.Search(ctx, options)
+	m.observe("search", start, err)
+	return result, err
+}
+
+func (m *Metrics) Revisions(ctx context.Context, key teamvault.Key) ([]teamvault.Revision, error) {
+	start := time.Now()
+	result, err := m.Connector.Revisions(ctx, key)
+	m.observe("revisions", start, err)
+	return result, err
+}
+
+func (m *Metrics) Secret(ctx context.Context, key teamvault.Key) (*teamvault.Secret, error) {
+	start := time.Now()
+	result, err := m.Connector.Secret(ctx, key)
+	m.observe("secret", start, err)
+	return result, err
+}
+
+func (m *Metrics) observe(method string, start time.Time, err error) {
+	labels := metrics.Labels{"method": method}
+	m.Registry.Inc("teamvault_connector_requests_total", "Calls of the connector.", labels)
+	m.Registry.Observe("teamvault_connector_request_duration_seconds", "Duration of the calls of the connector.", labels, time.Since(start).Seconds())
+	if err != nil {
+		m.Registry.Inc("teamvault_connector_errors_total", "Failed calls of the connector by error type.", metrics.Labels{"method": method, "type": teamvault.ErrorType(err)})
+	}
+}
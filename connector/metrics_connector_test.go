@@ -0,0 +1,106 @@
+package connector_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/bborbe/assert"
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/connector"
+	"github.com/bborbe/teamvault-utils/metrics"
+)
+
+func TestMetricsImplementsConnector(t *testing.T) {
+	c := &connector.Metrics{}
+	var i *teamvault.Connector
+	if err := AssertThat(c, Implements(i)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertMetrics(t *testing.T, registry *metrics.Registry, lines ...string) {
+	buf := &bytes.Buffer{}
+	if _, err := registry.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range lines {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Fatalf("line %q missing in:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestChainWithMetricsAndCache(t *testing.T) {
+	registry := metrics.NewRegistry()
+	memory := connector.NewMemory()
+	key, err := memory.Create(context.Background(), teamvault.NewSecret{
+		ContentType: teamvault.ContentTypePassword,
+		Name:        "db",
+		Password:    "S3CR3T",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := connector.Chain(memory, connector.CacheMiddleware(registry), connector.MetricsMiddleware(registry))
+	for i := 0; i < 3; i++ {
+		if _, err := c.Password(context.Background(), key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.Password(context.Background(), "missing"); err == nil {
+		t.Fatal("error expected")
+	}
+	assertMetrics(t, registry,
+		`teamvault_cache_requests_total{kind="password",result="hit"} 2`,
+		`teamvault_cache_requests_total{kind="password",result="miss"} 2`,
+		`teamvault_connector_requests_total{method="password"} 2`,
+		`teamvault_connector_request_duration_seconds_count{method="password"} 2`,
+		`teamvault_connector_errors_total{method="password",type="not_found"} 1`,
+	)
+}
+
+func TestDiskFallbackMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskfallback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	home := os.Getenv("HOME")
+	defer os.Setenv("HOME", home)
+	os.Setenv("HOME", dir)
+
+	registry := metrics.NewRegistry()
+	memory := connector.NewMemory()
+	key, err := memory.Create(context.Background(), teamvault.NewSecret{
+		ContentType: teamvault.ContentTypePassword,
+		Name:        "db",
+		Password:    "S3CR3T",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := connector.Chain(memory, connector.DiskFallbackMiddleware(registry))
+	if _, err := c.Password(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+	memory.Fail(key, teamvault.ErrServerUnavailable)
+	memory.Fail("missing", teamvault.ErrServerUnavailable)
+	password, err := c.Password(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password.String(), Is("S3CR3T")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Password(context.Background(), "missing"); err == nil {
+		t.Fatal("error expected")
+	}
+	assertMetrics(t, registry,
+		`teamvault_diskfallback_total{kind="password",result="used"} 1`,
+		`teamvault_diskfallback_total{kind="password",result="failed"} 1`,
+	)
+}
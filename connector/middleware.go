@@ -0,0 +1,50 @@
+package connector
+
+import (
+	"time"
+
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/metrics"
+)
+
+// Middleware wraps a connector to add behavior to all of its calls.
+type Middleware func(teamvault.Connector) teamvault.Connector
+
+// Chain wraps the connector with the middlewares. The first middleware is
+// the outermost and sees every call first.
+func Chain(connector teamvault.Connector, middlewares ...Middleware) teamvault.Connector {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		connector = middlewares[i](connector)
+	}
+	return connector
+}
+
+// MetricsMiddleware records count, latency and errors of all calls.
+func MetricsMiddleware(registry *metrics.Registry) Middleware {
+	return func(connector teamvault.Connector) teamvault.Connector {
+		return &Metrics{Connector: connector, Registry: registry}
+	}
+}
+
+// CacheMiddleware keeps results in memory and records hits and misses.
+func CacheMiddleware(registry *metrics.Registry) Middleware {
+	return CacheTTLMiddleware(registry, 0, 0)
+}
+
+// CacheTTLMiddleware keeps results in memory for the ttl and serves them
+// stale for staleWhileRevalidate while reading them again.
+func CacheTTLMiddleware(registry *metrics.Registry, ttl time.Duration, staleWhileRevalidate time.Duration) Middleware {
+	return func(connector teamvault.Connector) teamvault.Connector {
+		cache := NewCache(connector).WithTTL(ttl, staleWhileRevalidate)
+		cache.Metrics = registry
+		return cache
+	}
+}
+
+// DiskFallbackMiddleware serves values from disk if the connector fails
+// and records how often it does.
+func DiskFallbackMiddleware(registry *metrics.Registry) Middleware {
+	return func(connector teamvault.Connector) teamvault.Connector {
+		return &DiskFallback{Connector: connector, Metrics: registry}
+	}
+}
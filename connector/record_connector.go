@@ -0,0 +1,122 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/bborbe/teamvault-utils"
+)
+
+// Recorder records the results of all calls. With Fake, passwords, files
+// and credit cards are recorded as the values of Dummy, the caller still
+// gets the real values.
+type Recorder struct {
+	Connector teamvault.Connector
+	Fake      bool
+
+	mux      sync.Mutex
+	cassette Cassette
+}
+
+func (r *Recorder) Password(ctx context.Context, key teamvault.Key) (teamvault.Password, error) {
+	result, err := r.Connector.Password(ctx, key)
+	recorded := result
+	if err == nil && r.Fake {
+		recorded, _ = NewDummy().Password(ctx, key)
+	}
+	r.record(ctx, "password", key.String(), recorded, err)
+	return result, err
+}
+
+func (r *Recorder) User(ctx context.Context, key teamvault.Key) (teamvault.User, error) {
+	result, err := r.Connector.User(ctx, key)
+	r.record(ctx, "user", key.String(), result, err)
+	return result, err
+}
+
+func (r *Recorder) Url(ctx context.Context, key teamvault.Key) (teamvault.Url, error) {
+	result, err := r.Connector.Url(ctx, key)
+	r.record(ctx, "url", key.String(), result, err)
+	return result, err
+}
+
+func (r *Recorder) File(ctx context.Context, key teamvault.Key) (teamvault.File, error) {
+	result, err := r.Connector.File(ctx, key)
+	recorded := result
+	if err == nil && r.Fake {
+		recorded, _ = NewDummy().File(ctx, key)
+	}
+	r.record(ctx, "file", key.String(), recorded, err)
+	return result, err
+}
+
+func (r *Recorder) CreditCard(ctx context.Context, key teamvault.Key) (*teamvault.CreditCard, error) {
+	result, err := r.Connector.CreditCard(ctx, key)
+	recorded := result
+	if err == nil && r.Fake {
+		recorded, _ = NewDummy().CreditCard(ctx, key)
+	}
+	r.record(ctx, "creditcard", key.String(), recorded, err)
+	return result, err
+}
+
+func (r *Recorder) Secret(ctx context.Context, key teamvault.Key) (*teamvault.Secret, error) {
+	result, err := r.Connector.Secret(ctx, key)
+	r.record(ctx, "secret", key.String(), result, err)
+	return result, err
+}
+
+func (r *Recorder) Search(ctx context.Context, options teamvault.SearchOptions) ([]teamvault.Secret, error) {
+	result, err := r.Connector.Search(ctx, options)
+	r.record(ctx, "search", fmt.Sprintf("%+v", options), result, err)
+	return result, err
+}
+
+func (r *Recorder) Revisions(ctx context.Context, key teamvault.Key) ([]teamvault.Revision, error) {
+	result, err := r.Connector.Revisions(ctx, key)
+	r.record(ctx, "revisions", key.String(), result, err)
+	return result, err
+}
+
+// record keeps the result, calls aborted by the context are skipped.
+func (r *Recorder) record(ctx context.Context, method string, argument string, value interface{}, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	var entry CassetteEntry
+	if err != nil {
+		entry.Error = teamvault.ErrorType(err)
+		entry.Message = err.Error()
+	} else {
+		content, marshalErr := json.Marshal(value)
+		if marshalErr != nil {
+			entry.Error = "other"
+			entry.Message = marshalErr.Error()
+		}
+		entry.Value = content
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.cassette.Entries == nil {
+		r.cassette.Entries = make(map[string]CassetteEntry)
+	}
+	r.cassette.Entries[cassetteKey(method, argument)] = entry
+}
+
+// Cassette returns a copy of the recorded calls.
+func (r *Recorder) Cassette() *Cassette {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	result := &Cassette{Entries: make(map[string]CassetteEntry, len(r.cassette.Entries))}
+	for key, entry := range r.cassette.Entries {
+		result.Entries[key] = entry
+	}
+	return result
+}
+
+// Save writes the recorded calls to the cassette file.
+func (r *Recorder) Save(path string) error {
+	return r.Cassette().Write(path)
+}
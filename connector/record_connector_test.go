@@ -0,0 +1,117 @@
+package connector_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	. "github.com/bborbe/assert"
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/connector"
+)
+
+func TestRecorderImplementsConnector(t *testing.T) {
+	var i *teamvault.Connector
+	if err := AssertThat(&connector.Recorder{}, Implements(i)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(&connector.Replay{}, Implements(i)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func record(t *testing.T, fake bool) (*connector.Replay, teamvault.Key) {
+	ctx := context.Background()
+	memory := connector.NewMemory()
+	key, err := memory.Create(ctx, teamvault.NewSecret{
+		ContentType: teamvault.ContentTypePassword,
+		Name:        "db",
+		User:        "admin",
+		Password:    "S3CR3T",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &connector.Recorder{Connector: memory, Fake: fake}
+	password, err := recorder.Password(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("S3CR3T"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recorder.User(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recorder.Search(ctx, teamvault.SearchOptions{Name: "db"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recorder.Password(ctx, "missing"); err == nil {
+		t.Fatal("error expected")
+	}
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	if err := recorder.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	cassette, err := connector.ReadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return connector.NewReplay(cassette), key
+}
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	replay, key := record(t, false)
+	password, err := replay.Password(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("S3CR3T"))); err != nil {
+		t.Fatal(err)
+	}
+	user, err := replay.User(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(user, Is(teamvault.User("admin"))); err != nil {
+		t.Fatal(err)
+	}
+	secrets, err := replay.Search(ctx, teamvault.SearchOptions{Name: "db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(len(secrets), Is(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := replay.Password(ctx, "missing"); !errors.Is(err, teamvault.ErrNotFound) {
+		t.Fatalf("recorded not found expected, got %v", err)
+	}
+	if _, err := replay.Url(ctx, key); !errors.Is(err, connector.ErrNotRecorded) {
+		t.Fatalf("not recorded expected, got %v", err)
+	}
+}
+
+func TestRecorderFake(t *testing.T) {
+	ctx := context.Background()
+	replay, key := record(t, true)
+	password, err := replay.Password(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := connector.NewDummy().Password(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(expected)); err != nil {
+		t.Fatal(err)
+	}
+	user, err := replay.User(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(user, Is(teamvault.User("admin"))); err != nil {
+		t.Fatal(err)
+	}
+}
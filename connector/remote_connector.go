@@ -1,20 +1,44 @@
 package connector
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
-	http_header "github.com/bborbe/http/header"
-	"github.com/bborbe/http/rest"
 	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/auth"
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
 )
 
+// DefaultSecretTTL after which Remote reads the record of a secret again.
+const DefaultSecretTTL = time.Minute
+
+// Remote reads secrets from the Teamvault api. Each secret record is fetched
+// once per DefaultSecretTTL and each revision data document once, all
+// accessors are served from them.
 type Remote struct {
-	url  teamvault.Url
-	user teamvault.User
-	pass teamvault.Password
-	rest rest.Rest
+	url            teamvault.Url
+	authenticator  auth.Authenticator
+	executeRequest func(req *http.Request) (resp *http.Response, err error)
+	retry          RetryConfig
+	limiter        *Limiter
+	secrets        memo
+	data           memo
+	pinned         memo
+}
+
+// revisionData is the content of a secret revision.
+type revisionData struct {
+	Password teamvault.Password `json:"password"`
+	File     teamvault.File     `json:"file"`
+	teamvault.CreditCard
 }
 
 func NewRemote(
@@ -24,96 +48,424 @@ func NewRemote(
 	pass teamvault.Password,
 ) *Remote {
 	t := new(Remote)
-	t.rest = rest.New(executeRequest)
+	t.executeRequest = executeRequest
 	t.url = url
-	t.user = user
-	t.pass = pass
+	t.authenticator = auth.NewBasic(auth.Static(user, pass))
+	t.secrets.ttl = DefaultSecretTTL
+	return t
+}
+
+// WithAuthenticator replaces basic auth with user and password given to NewRemote.
+func (t *Remote) WithAuthenticator(authenticator auth.Authenticator) *Remote {
+	t.authenticator = authenticator
+	return t
+}
+
+// WithLimiter throttles the requests. Share the limiter between remotes to
+// share the limits.
+func (t *Remote) WithLimiter(limiter *Limiter) *Remote {
+	t.limiter = limiter
 	return t
 }
 
-func (t *Remote) Password(key teamvault.Key) (teamvault.Password, error) {
-	currentRevision, err := t.CurrentRevision(key)
+// WithTTL reads the record of a secret again once it is older than the
+// ttl instead of DefaultSecretTTL, so a new current revision is seen.
+// Revision data never changes.
+func (t *Remote) WithTTL(ttl time.Duration) *Remote {
+	t.secrets.ttl = ttl
+	return t
+}
+
+// WithRetry lets the remote repeat failed GET requests.
+func (t *Remote) WithRetry(retry RetryConfig) *Remote {
+	t.retry = retry
+	return t
+}
+
+func (t *Remote) Password(ctx context.Context, key teamvault.Key) (teamvault.Password, error) {
+	data, err := t.revisionData(ctx, key)
 	if err != nil {
 		return "", err
 	}
-	var response struct {
-		Password teamvault.Password `json:"password"`
+	return data.Password, nil
+}
+
+func (t *Remote) User(ctx context.Context, key teamvault.Key) (teamvault.User, error) {
+	secret, err := t.secret(ctx, key)
+	if err != nil {
+		return "", err
 	}
-	if err := t.rest.Call(fmt.Sprintf("%sdata", currentRevision.String()), nil, http.MethodGet, nil, &response, t.createHeader()); err != nil {
+	return secret.User, nil
+}
+
+func (t *Remote) Url(ctx context.Context, key teamvault.Key) (teamvault.Url, error) {
+	secret, err := t.secret(ctx, key)
+	if err != nil {
 		return "", err
 	}
-	return response.Password, nil
+	return secret.Url, nil
 }
 
-func (t *Remote) User(key teamvault.Key) (teamvault.User, error) {
-	var response struct {
-		User teamvault.User `json:"username"`
+func (t *Remote) File(ctx context.Context, key teamvault.Key) (teamvault.File, error) {
+	data, err := t.revisionData(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return data.File, nil
+}
+
+// CreditCard fails with ErrNotFound if the secret is no credit card.
+func (t *Remote) CreditCard(ctx context.Context, key teamvault.Key) (*teamvault.CreditCard, error) {
+	data, err := t.revisionData(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := t.secret(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if secret.ContentType != "" && secret.ContentType != teamvault.ContentTypeCreditCard || data.CreditCard.Number == "" {
+		return nil, fmt.Errorf("secret %v is no credit card: %w", key, teamvault.ErrNotFound)
 	}
-	if err := t.rest.Call(fmt.Sprintf("%s/api/secrets/%s/", t.url.String(), key.String()), nil, http.MethodGet, nil, &response, t.createHeader()); err != nil {
+	result := data.CreditCard
+	return &result, nil
+}
+
+func (t *Remote) Secret(ctx context.Context, key teamvault.Key) (*teamvault.Secret, error) {
+	secret, err := t.secret(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	result := *secret
+	return &result, nil
+}
+
+func (t *Remote) CurrentRevision(ctx context.Context, key teamvault.Key) (teamvault.TeamvaultCurrentRevision, error) {
+	secret, err := t.secret(ctx, key)
+	if err != nil {
 		return "", err
 	}
-	return response.User, nil
+	return secret.CurrentRevision, nil
+}
+
+// secret returns the record of the secret, fetched at most once per key.
+func (t *Remote) secret(ctx context.Context, key teamvault.Key) (*teamvault.Secret, error) {
+	key, _ = key.Split()
+	value, err := t.secrets.do(ctx, key.String(), func() (interface{}, error) {
+		var response teamvault.Secret
+		if err := t.call(ctx, http.MethodGet, t.secretUrl(key), nil, &response); err != nil {
+			return nil, err
+		}
+		response.Key = key
+		return &response, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*teamvault.Secret), nil
+}
+
+// revisionData returns the data of the revision pinned in the key or the
+// current revision, fetched at most once per revision.
+func (t *Remote) revisionData(ctx context.Context, key teamvault.Key) (*revisionData, error) {
+	revision, err := t.revision(ctx, key)
+	if err != nil {
+		return nil, t.accessRequestRequired(ctx, key, err)
+	}
+	value, err := t.data.do(ctx, revision.String(), func() (interface{}, error) {
+		var response revisionData
+		if err := t.call(ctx, http.MethodGet, fmt.Sprintf("%sdata", revision.String()), nil, &response); err != nil {
+			return nil, err
+		}
+		return &response, nil
+	})
+	if err != nil {
+		return nil, t.accessRequestRequired(ctx, key, err)
+	}
+	return value.(*revisionData), nil
+}
+
+// accessRequestRequired turns a forbidden error into ErrAccessRequestRequired
+// if access to the secret can be requested.
+func (t *Remote) accessRequestRequired(ctx context.Context, key teamvault.Key, err error) error {
+	requestError, ok := err.(*teamvault.RequestError)
+	if !ok || requestError.Kind != teamvault.ErrForbidden {
+		return err
+	}
+	secret, secretErr := t.secret(ctx, key)
+	if secretErr != nil || secret.AccessPolicy != teamvault.AccessPolicyRequest {
+		return err
+	}
+	result := *requestError
+	result.Kind = teamvault.ErrAccessRequestRequired
+	return &result
+}
+
+// revision returns the revision pinned in the key or the current revision.
+func (t *Remote) revision(ctx context.Context, key teamvault.Key) (teamvault.TeamvaultCurrentRevision, error) {
+	if _, revision := key.Split(); revision != "" {
+		if err := t.checkRevision(ctx, key); err != nil {
+			return "", err
+		}
+		return teamvault.TeamvaultCurrentRevision(fmt.Sprintf("%s/api/secret-revisions/%s/", t.url.String(), revision.String())), nil
+	}
+	return t.CurrentRevision(ctx, key)
+}
+
+// checkRevision fails with ErrNotFound if the revision pinned in the key is
+// not one of the revisions of the key, the data of another secret would be
+// returned otherwise. The result is kept per pinned key.
+func (t *Remote) checkRevision(ctx context.Context, key teamvault.Key) error {
+	_, err := t.pinned.do(ctx, key.String(), func() (interface{}, error) {
+		secretKey, revision := key.Split()
+		revisions, err := t.Revisions(ctx, secretKey)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range revisions {
+			if r.Id == revision {
+				return r, nil
+			}
+		}
+		return nil, fmt.Errorf("revision %v is not a revision of %v: %w", revision, secretKey, teamvault.ErrNotFound)
+	})
+	return err
+}
+
+func (t *Remote) Revisions(ctx context.Context, key teamvault.Key) ([]teamvault.Revision, error) {
+	var response struct {
+		Results []struct {
+			ApiUrl  teamvault.TeamvaultCurrentRevision `json:"api_url"`
+			Created time.Time                          `json:"created"`
+			SetBy   teamvault.User                     `json:"set_by"`
+		} `json:"results"`
+	}
+	if err := t.call(ctx, http.MethodGet, fmt.Sprintf("%srevisions/", t.secretUrl(key)), nil, &response); err != nil {
+		return nil, err
+	}
+	var result []teamvault.Revision
+	for _, re := range response.Results {
+		id, err := re.ApiUrl.Id()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, teamvault.Revision{
+			Id:      id,
+			Created: re.Created,
+			SetBy:   re.SetBy,
+		})
+	}
+	return result, nil
 }
 
-func (t *Remote) Url(key teamvault.Key) (teamvault.Url, error) {
+func (t *Remote) Create(ctx context.Context, secret teamvault.NewSecret) (teamvault.Key, error) {
+	if err := secret.Validate(); err != nil {
+		return "", err
+	}
+	request := struct {
+		ContentType  teamvault.ContentType  `json:"content_type"`
+		Name         teamvault.Name         `json:"name"`
+		Description  teamvault.Description  `json:"description,omitempty"`
+		User         teamvault.User         `json:"username,omitempty"`
+		Url          teamvault.Url          `json:"url,omitempty"`
+		Filename     teamvault.Filename     `json:"filename,omitempty"`
+		AccessPolicy teamvault.AccessPolicy `json:"access_policy,omitempty"`
+		SecretData   map[string]string      `json:"secret_data"`
+	}{
+		ContentType:  secret.ContentType,
+		Name:         secret.Name,
+		Description:  secret.Description,
+		User:         secret.User,
+		Url:          secret.Url,
+		Filename:     secret.Filename,
+		AccessPolicy: secret.AccessPolicy,
+		SecretData:   secretData(secret.Data()),
+	}
 	var response struct {
-		Url teamvault.Url `json:"url"`
+		ApiUrl teamvault.TeamvaultApiUrl `json:"api_url"`
 	}
-	if err := t.rest.Call(fmt.Sprintf("%s/api/secrets/%s/", t.url.String(), key.String()), nil, http.MethodGet, nil, &response, t.createHeader()); err != nil {
+	if err := t.call(ctx, http.MethodPost, fmt.Sprintf("%s/api/secrets/", t.url.String()), request, &response); err != nil {
 		return "", err
 	}
-	return response.Url, nil
+	return response.ApiUrl.Key()
 }
 
-func (t *Remote) CurrentRevision(key teamvault.Key) (teamvault.TeamvaultCurrentRevision, error) {
+func (t *Remote) Update(ctx context.Context, key teamvault.Key, data teamvault.SecretData) (teamvault.TeamvaultCurrentRevision, error) {
+	if err := data.Validate(); err != nil {
+		return "", err
+	}
+	request := struct {
+		SecretData map[string]string `json:"secret_data"`
+	}{
+		SecretData: secretData(data),
+	}
 	var response struct {
 		CurrentRevision teamvault.TeamvaultCurrentRevision `json:"current_revision"`
 	}
-	if err := t.rest.Call(fmt.Sprintf("%s/api/secrets/%s/", t.url.String(), key.String()), nil, http.MethodGet, nil, &response, t.createHeader()); err != nil {
+	if err := t.call(ctx, http.MethodPatch, t.secretUrl(key), request, &response); err != nil {
 		return "", err
 	}
+	key, _ = key.Split()
+	t.secrets.forget(key.String())
 	return response.CurrentRevision, nil
 }
 
-func (t *Remote) File(key teamvault.Key) (teamvault.File, error) {
-	rev, err := t.CurrentRevision(key)
-	if err != nil {
-		return "", fmt.Errorf("get current revision failed: %v", err)
+// RequestAccess files an access request for the secret with the given reason.
+func (t *Remote) RequestAccess(ctx context.Context, key teamvault.Key, reason teamvault.Reason) error {
+	request := struct {
+		Reason teamvault.Reason `json:"reason"`
+	}{
+		Reason: reason,
 	}
-	var response struct {
-		File teamvault.File `json:"file"`
+	return t.call(ctx, http.MethodPost, fmt.Sprintf("%saccess-requests/", t.secretUrl(key)), request, nil)
+}
+
+// secretData builds the write-only secret_data field Teamvault expects.
+func secretData(data teamvault.SecretData) map[string]string {
+	if data.File != "" {
+		return map[string]string{"file": data.File.String()}
 	}
-	if err := t.rest.Call(fmt.Sprintf("%sdata", rev.String()), nil, http.MethodGet, nil, &response, t.createHeader()); err != nil {
-		return "", err
+	result := map[string]string{"password": data.Password.String()}
+	if data.CreditCard != nil {
+		result["holder"] = data.CreditCard.Holder
+		result["number"] = data.CreditCard.Number
+		result["expiration_month"] = data.CreditCard.ExpirationMonth
+		result["expiration_year"] = data.CreditCard.ExpirationYear
+		result["security_code"] = data.CreditCard.SecurityCode
 	}
-	return response.File, nil
+	return result
 }
 
-func (t *Remote) createHeader() http.Header {
+// secretUrl returns the api url of the secret, ignoring a pinned revision.
+func (t *Remote) secretUrl(key teamvault.Key) string {
+	key, _ = key.Split()
+	return fmt.Sprintf("%s/api/secrets/%s/", t.url.String(), key.String())
+}
+
+func (t *Remote) createHeader(ctx context.Context) (http.Header, error) {
+	authorization, err := t.authenticator.Authorization(ctx)
+	if err != nil {
+		return nil, err
+	}
 	header := make(http.Header)
-	header.Add("Authorization", fmt.Sprintf("Basic %s", http_header.CreateAuthorizationToken(t.user.String(), t.pass.String())))
+	header.Add("Authorization", authorization)
 	header.Add("Content-Type", "application/json")
-	return header
+	return header, nil
 }
 
-func (t *Remote) Search(search string) ([]teamvault.Key, error) {
-	var response struct {
-		Results []struct {
-			ApiUrl teamvault.TeamvaultApiUrl `json:"api_url"`
-		} `json:"results"`
+// call sends the request bound to the given context and decodes the json response.
+// Failures are returned as *teamvault.RequestError.
+func (t *Remote) call(ctx context.Context, method string, url string, request interface{}, response interface{}) error {
+	glog.V(4).Infof("%s %s", method, url)
+	var body io.Reader
+	if request != nil {
+		content, err := json.Marshal(request)
+		if err != nil {
+			return errors.Wrap(err, "marshal request failed")
+		}
+		body = bytes.NewBuffer(content)
 	}
-	values := url.Values{}
-	values.Add("search", search)
-	if err := t.rest.Call(fmt.Sprintf("%s/api/secrets/", t.url.String()), values, http.MethodGet, nil, &response, t.createHeader()); err != nil {
-		return nil, err
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return errors.Wrap(err, "build request failed")
 	}
-	var result []teamvault.Key
-	for _, re := range response.Results {
-		key, err := re.ApiUrl.Key()
+	req.Header, err = t.createHeader(ctx)
+	if err != nil {
+		return errors.Wrap(err, "authenticate failed")
+	}
+	executeRequest := t.executeRequest
+	if t.limiter != nil {
+		executeRequest = limit(t.limiter, executeRequest)
+	}
+	resp, err := executeWithRetry(executeRequest, t.retry, req.WithContext(ctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		glog.V(2).Infof("%s %s failed: %v", method, url, err)
+		return &teamvault.RequestError{
+			Method: method,
+			Url:    url,
+			Kind:   teamvault.ErrServerUnavailable,
+			Err:    err,
+		}
+	}
+	if resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	if resp.StatusCode/100 != 2 {
+		glog.V(2).Infof("%s %s failed with status: %d", method, url, resp.StatusCode)
+		return teamvault.NewRequestError(method, url, resp.StatusCode)
+	}
+	if response != nil {
+		if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+			return errors.Wrap(err, "decode response failed")
+		}
+	}
+	return nil
+}
+
+// Search follows the pagination of the api until all results are read or the limit is reached.
+// It stops at an empty page or a page read before.
+func (t *Remote) Search(ctx context.Context, options teamvault.SearchOptions) ([]teamvault.Secret, error) {
+	values := url.Values{}
+	values.Add("search", options.Name)
+	next := fmt.Sprintf("%s/api/secrets/?%s", t.url.String(), values.Encode())
+	read := make(map[string]bool)
+	var result []teamvault.Secret
+	for next != "" && !read[next] && !options.Full(result) {
+		read[next] = true
+		var response struct {
+			Next    *string            `json:"next"`
+			Results []teamvault.Secret `json:"results"`
+		}
+		if err := t.call(ctx, http.MethodGet, next, nil, &response); err != nil {
+			return nil, err
+		}
+		for _, secret := range response.Results {
+			key, err := secret.ApiUrl.Key()
+			if err != nil {
+				return nil, err
+			}
+			secret.Key = key
+			if options.Matches(secret) && !options.Full(result) {
+				result = append(result, secret)
+			}
+		}
+		if len(response.Results) == 0 || response.Next == nil {
+			break
+		}
+		var err error
+		next, err = t.nextPage(next, *response.Next)
 		if err != nil {
 			return nil, err
 		}
-		result = append(result, key)
 	}
 	return result, nil
 }
+
+// nextPage resolves the next link of a search page against the page. Links
+// to other hosts are refused, the credentials would be sent there.
+func (t *Remote) nextPage(page string, next string) (string, error) {
+	if next == "" {
+		return "", nil
+	}
+	base, err := url.Parse(page)
+	if err != nil {
+		return "", errors.Wrapf(err, "parse url %s failed", page)
+	}
+	ref, err := url.Parse(next)
+	if err != nil {
+		return "", errors.Wrapf(err, "parse next page %s failed", next)
+	}
+	configured, err := url.Parse(t.url.String())
+	if err != nil {
+		return "", errors.Wrapf(err, "parse url %s failed", t.url)
+	}
+	result := base.ResolveReference(ref)
+	if !strings.EqualFold(result.Host, configured.Host) {
+		return "", fmt.Errorf("next page %s is not on %s", next, configured.Host)
+	}
+	result.Scheme = configured.Scheme
+	return result.String(), nil
+}
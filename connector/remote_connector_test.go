@@ -2,13 +2,18 @@ package connector_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"testing"
 
 	. "github.com/bborbe/assert"
 	"github.com/bborbe/io/reader_nop_close"
 	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/auth"
 	"github.com/bborbe/teamvault-utils/connector"
 )
 
@@ -43,7 +48,7 @@ func TestTeamvaultPassword(t *testing.T) {
 		}
 		return &http.Response{StatusCode: 404}, fmt.Errorf("invalid url %v", req.URL.String())
 	}, "http://teamvault.example.com", "user", "pass")
-	password, err := tv.Password(key)
+	password, err := tv.Password(context.Background(), key)
 	if err := AssertThat(err, NilValue()); err != nil {
 		t.Fatal(err)
 	}
@@ -52,10 +57,172 @@ func TestTeamvaultPassword(t *testing.T) {
 	}
 }
 
+func TestTeamvaultSingleRoundTrip(t *testing.T) {
+	key := teamvault.Key("key123")
+	counter := make(map[string]int)
+	tv := connector.NewRemote(func(req *http.Request) (resp *http.Response, err error) {
+		counter[req.URL.String()]++
+		if req.URL.String() == "http://teamvault.example.com/api/secrets/key123/" {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       reader_nop_close.New(bytes.NewBufferString(`{"username":"user","url":"https://example.com","current_revision":"https://teamvault.example.com/api/secret-revisions/ref123/"}`)),
+			}, nil
+		}
+		if req.URL.String() == "https://teamvault.example.com/api/secret-revisions/ref123/data" {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       reader_nop_close.New(bytes.NewBufferString(`{"password":"S3CR3T"}`)),
+			}, nil
+		}
+		return &http.Response{StatusCode: 404}, fmt.Errorf("invalid url %v", req.URL.String())
+	}, "http://teamvault.example.com", "user", "pass")
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := tv.User(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tv.Password(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tv.Url(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := AssertThat(len(counter), Is(2)); err != nil {
+		t.Fatal(err)
+	}
+	for url, count := range counter {
+		if err := AssertThat(count, Is(1)); err != nil {
+			t.Fatalf("%s: %v", url, err)
+		}
+	}
+}
+
+func pinnedRevisionRequest(counter map[string]int) func(req *http.Request) (resp *http.Response, err error) {
+	responses := map[string]string{
+		"http://teamvault.example.com/api/secrets/key123/revisions/":    `{"results":[{"api_url":"https://teamvault.example.com/api/secret-revisions/rev456/"}]}`,
+		"http://teamvault.example.com/api/secrets/other/revisions/":     `{"results":[{"api_url":"https://teamvault.example.com/api/secret-revisions/rev789/"}]}`,
+		"http://teamvault.example.com/api/secret-revisions/rev456/data": `{"password":"OLD"}`,
+		"http://teamvault.example.com/api/secret-revisions/rev789/data": `{"password":"OTHER"}`,
+	}
+	return func(req *http.Request) (resp *http.Response, err error) {
+		counter[req.URL.String()]++
+		if content, ok := responses[req.URL.String()]; ok {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       reader_nop_close.New(bytes.NewBufferString(content)),
+			}, nil
+		}
+		return &http.Response{StatusCode: 404}, fmt.Errorf("invalid url %v", req.URL.String())
+	}
+}
+
+func TestTeamvaultPasswordPinnedRevision(t *testing.T) {
+	key := teamvault.Key("key123@rev456")
+	counter := make(map[string]int)
+	tv := connector.NewRemote(pinnedRevisionRequest(counter), "http://teamvault.example.com", "user", "pass")
+	for i := 0; i < 2; i++ {
+		password, err := tv.Password(context.Background(), key)
+		if err := AssertThat(err, NilValue()); err != nil {
+			t.Fatal(err)
+		}
+		if err := AssertThat(password.String(), Is("OLD")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := AssertThat(counter["http://teamvault.example.com/api/secrets/key123/revisions/"], Is(1)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTeamvaultPasswordPinnedRevisionOfOtherKey(t *testing.T) {
+	counter := make(map[string]int)
+	tv := connector.NewRemote(pinnedRevisionRequest(counter), "http://teamvault.example.com", "user", "pass")
+	_, err := tv.Password(context.Background(), "key123@rev789")
+	if !errors.Is(err, teamvault.ErrNotFound) {
+		t.Fatalf("not found expected, got %v", err)
+	}
+	if err := AssertThat(counter["http://teamvault.example.com/api/secret-revisions/rev789/data"], Is(0)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTeamvaultRevisions(t *testing.T) {
+	key := teamvault.Key("key123")
+	tv := connector.NewRemote(createRequest(`{
+  "count": 2,
+  "next": null,
+  "previous": null,
+  "results": [
+    {
+      "api_url": "https://teamvault.example.com/api/secret-revisions/rKp1x5/",
+      "created": "2017-08-21T12:29:53.252282Z",
+      "set_by": "skegel"
+    },
+    {
+      "api_url": "https://teamvault.example.com/api/secret-revisions/rev456/",
+      "created": "2017-09-01T08:00:00Z",
+      "set_by": "bborbe"
+    }
+  ]
+}`, "http://teamvault.example.com/api/secrets/key123/revisions/"), "http://teamvault.example.com", "user", "pass")
+	revisions, err := tv.Revisions(context.Background(), key)
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(len(revisions), Is(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(revisions[0].Id.String(), Is("rKp1x5")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(revisions[1].SetBy.String(), Is("bborbe")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(revisions[1].Created.Year(), Is(2017)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTeamvaultCreditCard(t *testing.T) {
+	key := teamvault.Key("key123")
+	tv := connector.NewRemote(func(req *http.Request) (resp *http.Response, err error) {
+		if req.URL.String() == "http://teamvault.example.com/api/secrets/key123/" {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       reader_nop_close.New(bytes.NewBufferString(`{"content_type":"cc","current_revision":"https://teamvault.example.com/api/secret-revisions/ref123/"}`)),
+			}, nil
+		}
+		if req.URL.String() == "https://teamvault.example.com/api/secret-revisions/ref123/data" {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       reader_nop_close.New(bytes.NewBufferString(`{"holder":"Ben","number":"4111111111111111","expiration_month":"03","expiration_year":"2030","security_code":"123","password":""}`)),
+			}, nil
+		}
+		return &http.Response{StatusCode: 404}, fmt.Errorf("invalid url %v", req.URL.String())
+	}, "http://teamvault.example.com", "user", "pass")
+	creditCard, err := tv.CreditCard(context.Background(), key)
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(creditCard.Holder, Is("Ben")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(creditCard.Number, Is("4111111111111111")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(creditCard.Expiration(), Is("03/2030")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(creditCard.SecurityCode, Is("123")); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestTeamvaultUser(t *testing.T) {
 	key := teamvault.Key("key123")
 	tv := connector.NewRemote(createRequest(`{"username":"user"}`, "http://teamvault.example.com/api/secrets/key123/"), "http://teamvault.example.com", "user", "pass")
-	user, err := tv.User(key)
+	user, err := tv.User(context.Background(), key)
 	if err := AssertThat(err, NilValue()); err != nil {
 		t.Fatal(err)
 	}
@@ -67,7 +234,7 @@ func TestTeamvaultUser(t *testing.T) {
 func TestTeamvaultUrl(t *testing.T) {
 	key := teamvault.Key("key123")
 	tv := connector.NewRemote(createRequest(`{"url":"https://example.com"}`, "http://teamvault.example.com/api/secrets/key123/"), "http://teamvault.example.com", "user", "pass")
-	url, err := tv.Url(key)
+	url, err := tv.Url(context.Background(), key)
 	if err := AssertThat(err, NilValue()); err != nil {
 		t.Fatal(err)
 	}
@@ -76,6 +243,128 @@ func TestTeamvaultUrl(t *testing.T) {
 	}
 }
 
+func TestTeamvaultUserCanceled(t *testing.T) {
+	key := teamvault.Key("key123")
+	tv := connector.NewRemote(func(req *http.Request) (resp *http.Response, err error) {
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+		return createRequest(`{"username":"user"}`, "http://teamvault.example.com/api/secrets/key123/")(req)
+	}, "http://teamvault.example.com", "user", "pass")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := tv.User(ctx, key)
+	if err := AssertThat(err, Is(context.Canceled)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSecret(t *testing.T) {
+	key := teamvault.Key("key123")
+	tv := connector.NewRemote(createRequest(`{
+  "access_policy": "request",
+  "allowed_groups": [],
+  "allowed_users": [],
+  "api_url": "https://teamvault.example.com/api/secrets/key123/",
+  "content_type": "file",
+  "created": "2017-08-21T12:29:53.252282Z",
+  "created_by": "skegel",
+  "current_revision": "https://teamvault.example.com/api/secret-revisions/rKp1x5/",
+  "data_readable": [],
+  "description": "my cert",
+  "filename": "cert.pem",
+  "last_read": null,
+  "name": "SearchString",
+  "needs_changing_on_leave": true,
+  "status": "ok",
+  "url": "https://example.com",
+  "username": "foo",
+  "web_url": "https://teamvault.example.com/secrets/key123"
+}`, "http://teamvault.example.com/api/secrets/key123/"), "http://teamvault.example.com", "user", "pass")
+	secret, err := tv.Secret(context.Background(), key)
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(secret.Key, Is(key)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(secret.ContentType, Is(teamvault.ContentTypeFile)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(secret.Filename.String(), Is("cert.pem")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(secret.AccessPolicy, Is(teamvault.AccessPolicyRequest)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(secret.CreatedBy.String(), Is("skegel")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(secret.NeedsChangingOnLeave, Is(true)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(secret.LastRead.IsZero(), Is(true)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTeamvaultErrors(t *testing.T) {
+	var tests = []struct {
+		name     string
+		policy   string
+		status   int
+		expected error
+	}{
+		{"not found", "everyone", 404, teamvault.ErrNotFound},
+		{"unauthorized", "everyone", 401, teamvault.ErrUnauthorized},
+		{"forbidden", "hidden", 403, teamvault.ErrForbidden},
+		{"access request required", "request", 403, teamvault.ErrAccessRequestRequired},
+		{"server unavailable", "everyone", 502, teamvault.ErrServerUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tv := connector.NewRemote(func(req *http.Request) (resp *http.Response, err error) {
+				if req.URL.String() == "http://teamvault.example.com/api/secrets/key123/" {
+					return &http.Response{
+						StatusCode: 200,
+						Body:       reader_nop_close.New(bytes.NewBufferString(fmt.Sprintf(`{"access_policy":"%s","current_revision":"https://teamvault.example.com/api/secret-revisions/ref123/"}`, tt.policy))),
+					}, nil
+				}
+				return &http.Response{
+					StatusCode: tt.status,
+					Body:       reader_nop_close.New(bytes.NewBufferString(`{}`)),
+				}, nil
+			}, "http://teamvault.example.com", "user", "pass")
+			_, err := tv.Password(context.Background(), "key123")
+			if err := AssertThat(errors.Is(err, tt.expected), Is(true)); err != nil {
+				t.Fatalf("%v: %v", tt.expected, err)
+			}
+			var requestError *teamvault.RequestError
+			if err := AssertThat(errors.As(err, &requestError), Is(true)); err != nil {
+				t.Fatal(err)
+			}
+			if err := AssertThat(requestError.StatusCode, Is(tt.status)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCacheAndDiskFallbackPreserveErrors(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	remote := connector.NewRemote(func(req *http.Request) (resp *http.Response, err error) {
+		return &http.Response{
+			StatusCode: 404,
+			Body:       reader_nop_close.New(bytes.NewBufferString(`{}`)),
+		}, nil
+	}, "http://teamvault.example.com", "user", "pass")
+	tv := connector.NewCache(&connector.DiskFallback{Connector: remote})
+	_, err := tv.User(context.Background(), "key123")
+	if err := AssertThat(errors.Is(err, teamvault.ErrNotFound), Is(true)); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestSearch(t *testing.T) {
 	tv := connector.NewRemote(createRequest(`{
   "count": 1,
@@ -104,14 +393,139 @@ func TestSearch(t *testing.T) {
     }
   ]
 }`, "http://teamvault.example.com/api/secrets/?search=searchString"), "http://teamvault.example.com", "user", "pass")
-	matches, err := tv.Search("searchString")
+	matches, err := tv.Search(context.Background(), teamvault.SearchOptions{Name: "searchString"})
 	if err := AssertThat(err, NilValue()); err != nil {
 		t.Fatal(err)
 	}
 	if err := AssertThat(len(matches), Is(1)); err != nil {
 		t.Fatal(err)
 	}
-	if err := AssertThat(matches[0].String(), Is("key123")); err != nil {
+	if err := AssertThat(matches[0].Key.String(), Is("key123")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(matches[0].Name.String(), Is("SearchString")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(matches[0].ContentType, Is(teamvault.ContentTypePassword)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSearchPagination(t *testing.T) {
+	pages := map[string]string{
+		"http://teamvault.example.com/api/secrets/?search=db": `{
+  "count": 3,
+  "next": "http://teamvault.example.com/api/secrets/?page=2&search=db",
+  "results": [
+    {"api_url": "https://teamvault.example.com/api/secrets/key1/", "name": "db", "content_type": "password", "status": "ok"},
+    {"api_url": "https://teamvault.example.com/api/secrets/key2/", "name": "db cert", "content_type": "file", "status": "ok"}
+  ]
+}`,
+		"http://teamvault.example.com/api/secrets/?page=2&search=db": `{
+  "count": 3,
+  "next": null,
+  "results": [
+    {"api_url": "https://teamvault.example.com/api/secrets/key3/", "name": "db replica", "content_type": "password", "status": "ok"}
+  ]
+}`,
+	}
+	tv := connector.NewRemote(func(req *http.Request) (resp *http.Response, err error) {
+		content, ok := pages[req.URL.String()]
+		if !ok {
+			return &http.Response{StatusCode: 404}, fmt.Errorf("invalid url %v", req.URL.String())
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       reader_nop_close.New(bytes.NewBufferString(content)),
+		}, nil
+	}, "http://teamvault.example.com", "user", "pass")
+	var tests = []struct {
+		name     string
+		options  teamvault.SearchOptions
+		expected []teamvault.Key
+	}{
+		{"all pages", teamvault.SearchOptions{Name: "db"}, []teamvault.Key{"key1", "key2", "key3"}},
+		{"content type", teamvault.SearchOptions{Name: "db", ContentType: teamvault.ContentTypePassword}, []teamvault.Key{"key1", "key3"}},
+		{"exact name", teamvault.SearchOptions{Name: "db", ExactName: true}, []teamvault.Key{"key1"}},
+		{"limit", teamvault.SearchOptions{Name: "db", Limit: 1}, []teamvault.Key{"key1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := tv.Search(context.Background(), tt.options)
+			if err := AssertThat(err, NilValue()); err != nil {
+				t.Fatal(err)
+			}
+			var keys []teamvault.Key
+			for _, match := range matches {
+				keys = append(keys, match.Key)
+			}
+			if err := AssertThat(fmt.Sprint(keys), Is(fmt.Sprint(tt.expected))); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	var body map[string]interface{}
+	tv := connector.NewRemote(func(req *http.Request) (resp *http.Response, err error) {
+		if req.Method != http.MethodPost || req.URL.String() != "http://teamvault.example.com/api/secrets/" {
+			return &http.Response{StatusCode: 404}, fmt.Errorf("invalid request %v %v", req.Method, req.URL.String())
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: 201,
+			Body:       reader_nop_close.New(bytes.NewBufferString(`{"api_url":"https://teamvault.example.com/api/secrets/key123/"}`)),
+		}, nil
+	}, "http://teamvault.example.com", "user", "pass")
+	key, err := tv.Create(context.Background(), teamvault.NewSecret{
+		ContentType:  teamvault.ContentTypePassword,
+		Name:         "My Service",
+		User:         "admin",
+		AccessPolicy: teamvault.AccessPolicyHidden,
+		Password:     "S3CR3T",
+	})
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(key.String(), Is("key123")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(body["name"], Is("My Service")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(body["access_policy"], Is("hidden")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(body["secret_data"].(map[string]interface{})["password"], Is("S3CR3T")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	var body map[string]interface{}
+	tv := connector.NewRemote(func(req *http.Request) (resp *http.Response, err error) {
+		if req.Method != http.MethodPatch || req.URL.String() != "http://teamvault.example.com/api/secrets/key123/" {
+			return &http.Response{StatusCode: 404}, fmt.Errorf("invalid request %v %v", req.Method, req.URL.String())
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       reader_nop_close.New(bytes.NewBufferString(`{"current_revision":"https://teamvault.example.com/api/secret-revisions/rev456/"}`)),
+		}, nil
+	}, "http://teamvault.example.com", "user", "pass")
+	revision, err := tv.Update(context.Background(), "key123", teamvault.SecretData{Password: "N3W"})
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(revision.String(), Is("https://teamvault.example.com/api/secret-revisions/rev456/")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(body["secret_data"].(map[string]interface{})["password"], Is("N3W")); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -133,3 +547,80 @@ func createRequest(content string, validUrl string) func(req *http.Request) (res
 		return &http.Response{StatusCode: 404}, fmt.Errorf("invalid url %v", req.URL.String())
 	}
 }
+
+func TestTeamvaultAuthenticator(t *testing.T) {
+	var authorization string
+	tv := connector.NewRemote(func(req *http.Request) (resp *http.Response, err error) {
+		authorization = req.Header.Get("Authorization")
+		return &http.Response{
+			StatusCode: 200,
+			Body:       reader_nop_close.New(bytes.NewBufferString(`{"username":"foo"}`)),
+		}, nil
+	}, "http://teamvault.example.com", "user", "pass").WithAuthenticator(auth.NewBearer("T0K3N"))
+	if _, err := tv.User(context.Background(), "key123"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(authorization, Is("Bearer T0K3N")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTeamvaultRequestAccess(t *testing.T) {
+	var method, url, body string
+	tv := connector.NewRemote(func(req *http.Request) (resp *http.Response, err error) {
+		method = req.Method
+		url = req.URL.String()
+		content, _ := ioutil.ReadAll(req.Body)
+		body = string(content)
+		return &http.Response{
+			StatusCode: 201,
+			Body:       reader_nop_close.New(bytes.NewBufferString(`{}`)),
+		}, nil
+	}, "http://teamvault.example.com", "user", "pass")
+	if err := tv.RequestAccess(context.Background(), "key123@rev1", "first deploy"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(method, Is(http.MethodPost)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(url, Is("http://teamvault.example.com/api/secrets/key123/access-requests/")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(body, Is(`{"reason":"first deploy"}`)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSearchNextPage(t *testing.T) {
+	var tests = []struct {
+		name    string
+		next    string
+		pages   int
+		success bool
+	}{
+		{"same page", `"http://teamvault.example.com/api/secrets/?search=db"`, 1, true},
+		{"relative", `"/api/secrets/?search=db"`, 1, true},
+		{"other host", `"http://evil.example.com/api/secrets/?page=2"`, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pages int
+			tv := connector.NewRemote(func(req *http.Request) (resp *http.Response, err error) {
+				pages++
+				return &http.Response{
+					StatusCode: 200,
+					Body: reader_nop_close.New(bytes.NewBufferString(fmt.Sprintf(`{"next": %s, "results": [
+    {"api_url": "https://teamvault.example.com/api/secrets/key1/", "name": "db", "content_type": "password", "status": "ok"}
+  ]}`, tt.next))),
+				}, nil
+			}, "http://teamvault.example.com", "user", "pass")
+			_, err := tv.Search(context.Background(), teamvault.SearchOptions{Name: "db"})
+			if err := AssertThat(err == nil, Is(tt.success)); err != nil {
+				t.Fatal(err)
+			}
+			if err := AssertThat(pages, Is(tt.pages)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
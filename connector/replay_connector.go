@@ -0,0 +1,97 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/bborbe/teamvault-utils"
+)
+
+// Replay answers all calls from the cassette without Teamvault. Calls
+// missing in the cassette fail with ErrNotRecorded.
+type Replay struct {
+	Cassette *Cassette
+}
+
+func NewReplay(cassette *Cassette) *Replay {
+	return &Replay{Cassette: cassette}
+}
+
+func (r *Replay) Password(ctx context.Context, key teamvault.Key) (teamvault.Password, error) {
+	var result teamvault.Password
+	err := r.replay("password", key.String(), &result)
+	return result, err
+}
+
+func (r *Replay) User(ctx context.Context, key teamvault.Key) (teamvault.User, error) {
+	var result teamvault.User
+	err := r.replay("user", key.String(), &result)
+	return result, err
+}
+
+func (r *Replay) Url(ctx context.Context, key teamvault.Key) (teamvault.Url, error) {
+	var result teamvault.Url
+	err := r.replay("url", key.String(), &result)
+	return result, err
+}
+
+func (r *Replay) File(ctx context.Context, key teamvault.Key) (teamvault.File, error) {
+	var result teamvault.File
+	err := r.replay("file", key.String(), &result)
+	return result, err
+}
+
+func (r *Replay) CreditCard(ctx context.Context, key teamvault.Key) (*teamvault.CreditCard, error) {
+	var result teamvault.CreditCard
+	if err := r.replay("creditcard", key.String(), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (r *Replay) Secret(ctx context.Context, key teamvault.Key) (*teamvault.Secret, error) {
+	var result teamvault.Secret
+	if err := r.replay("secret", key.String(), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (r *Replay) Search(ctx context.Context, options teamvault.SearchOptions) ([]teamvault.Secret, error) {
+	var result []teamvault.Secret
+	err := r.replay("search", fmt.Sprintf("%+v", options), &result)
+	return result, err
+}
+
+func (r *Replay) Revisions(ctx context.Context, key teamvault.Key) ([]teamvault.Revision, error) {
+	var result []teamvault.Revision
+	err := r.replay("revisions", key.String(), &result)
+	return result, err
+}
+
+func (r *Replay) Create(ctx context.Context, secret teamvault.NewSecret) (teamvault.Key, error) {
+	return "", fmt.Errorf("create not supported by replay")
+}
+
+func (r *Replay) Update(ctx context.Context, key teamvault.Key, data teamvault.SecretData) (teamvault.TeamvaultCurrentRevision, error) {
+	return "", fmt.Errorf("update not supported by replay")
+}
+
+func (r *Replay) RequestAccess(ctx context.Context, key teamvault.Key, reason teamvault.Reason) error {
+	return fmt.Errorf("request access not supported by replay")
+}
+
+func (r *Replay) replay(method string, argument string, value interface{}) error {
+	entry, ok := r.Cassette.Entries[cassetteKey(method, argument)]
+	if !ok {
+		return fmt.Errorf("%s %s %w in cassette", method, argument, ErrNotRecorded)
+	}
+	if entry.Error != "" {
+		return errorOfType(entry.Error, entry.Message)
+	}
+	if err := json.Unmarshal(entry.Value, value); err != nil {
+		return fmt.Errorf("decode %s %s failed: %v", method, argument, err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package connector
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// RetryConfig defines how often and how long Remote waits before repeating
+// an idempotent request that failed with a network error, 429 or 5xx.
+type RetryConfig struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxRetries:     3,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+	}
+}
+
+// backoff returns the exponential backoff for the given attempt with jitter
+// between half and the full duration.
+func (r RetryConfig) backoff(attempt int) time.Duration {
+	backoff := r.InitialBackoff
+	for i := 0; i < attempt && backoff < r.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > r.MaxBackoff {
+		backoff = r.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// wait returns how long to wait before the next attempt. A Retry-After
+// header sent by the server takes precedence over the backoff, limited to
+// MaxBackoff.
+func (r RetryConfig) wait(resp *http.Response, attempt int) time.Duration {
+	if resp != nil {
+		if wait, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			if wait > r.MaxBackoff {
+				return r.MaxBackoff
+			}
+			return wait
+		}
+	}
+	return r.backoff(attempt)
+}
+
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5
+}
+
+func idempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// executeWithRetry sends the request and repeats it as configured.
+func executeWithRetry(
+	executeRequest func(req *http.Request) (resp *http.Response, err error),
+	config RetryConfig,
+	req *http.Request,
+) (*http.Response, error) {
+	if config.MaxRetries <= 0 || !idempotent(req.Method) {
+		return executeRequest(req)
+	}
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		glog.V(2).Infof("%s %s attempt %d/%d", req.Method, req.URL.String(), attempt+1, config.MaxRetries+1)
+		resp, err := executeRequest(req)
+		if ctx.Err() != nil || !retryable(resp, err) || attempt >= config.MaxRetries {
+			return resp, err
+		}
+		wait := config.wait(resp, attempt)
+		if err != nil {
+			glog.Warningf("%s %s attempt %d/%d failed: %v, retry in %v", req.Method, req.URL.String(), attempt+1, config.MaxRetries+1, err, wait)
+		} else {
+			glog.Warningf("%s %s attempt %d/%d failed with status %d, retry in %v", req.Method, req.URL.String(), attempt+1, config.MaxRetries+1, resp.StatusCode, wait)
+		}
+		if resp != nil && resp.Body != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
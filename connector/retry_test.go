@@ -0,0 +1,154 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/bborbe/assert"
+	"github.com/bborbe/io/reader_nop_close"
+)
+
+func TestRetryConfigBackoff(t *testing.T) {
+	config := RetryConfig{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+	}
+	var tests = []struct {
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{0, 50 * time.Millisecond, 100 * time.Millisecond},
+		{1, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond, 800 * time.Millisecond},
+		{10, 500 * time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("attempt %d", tt.attempt), func(t *testing.T) {
+			backoff := config.backoff(tt.attempt)
+			if backoff < tt.min || backoff > tt.max {
+				t.Fatalf("backoff %v not in [%v, %v]", backoff, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var tests = []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"invalid", 0, false},
+		{"Mon, 02 Jan 2006 15:04:05 GMT", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			wait, ok := retryAfter(tt.value)
+			if ok != tt.ok || wait != tt.expected {
+				t.Fatalf("expected %v %v got %v %v", tt.expected, tt.ok, wait, ok)
+			}
+		})
+	}
+}
+
+func TestRetryConfigWaitCapsRetryAfter(t *testing.T) {
+	config := RetryConfig{MaxBackoff: time.Second}
+	header := http.Header{}
+	header.Set("Retry-After", "86400")
+	wait := config.wait(&http.Response{StatusCode: http.StatusTooManyRequests, Header: header}, 0)
+	if err := AssertThat(wait, Is(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExecuteWithRetry(t *testing.T) {
+	config := RetryConfig{
+		MaxRetries:     3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	}
+	var tests = []struct {
+		name             string
+		method           string
+		responses        []int
+		expectedStatus   int
+		expectedAttempts int
+	}{
+		{"success", http.MethodGet, []int{200}, 200, 1},
+		{"network error", http.MethodGet, []int{0, 200}, 200, 2},
+		{"too many requests", http.MethodGet, []int{429, 200}, 200, 2},
+		{"server error", http.MethodGet, []int{502, 503, 200}, 200, 3},
+		{"give up", http.MethodGet, []int{502, 502, 502, 502, 200}, 502, 4},
+		{"not found", http.MethodGet, []int{404, 200}, 404, 1},
+		{"post", http.MethodPost, []int{502, 200}, 502, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			executeRequest := func(req *http.Request) (*http.Response, error) {
+				status := tt.responses[attempts]
+				attempts++
+				if status == 0 {
+					return nil, fmt.Errorf("connection refused")
+				}
+				return &http.Response{
+					StatusCode: status,
+					Header:     make(http.Header),
+					Body:       reader_nop_close.New(bytes.NewBufferString("")),
+				}, nil
+			}
+			req, err := http.NewRequest(tt.method, "http://teamvault.example.com/api/secrets/key123/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := executeWithRetry(executeRequest, config, req)
+			if err := AssertThat(err, NilValue()); err != nil {
+				t.Fatal(err)
+			}
+			if err := AssertThat(resp.StatusCode, Is(tt.expectedStatus)); err != nil {
+				t.Fatal(err)
+			}
+			if err := AssertThat(attempts, Is(tt.expectedAttempts)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestExecuteWithRetryHonorsRetryAfter(t *testing.T) {
+	config := RetryConfig{
+		MaxRetries:     1,
+		InitialBackoff: time.Hour,
+		MaxBackoff:     time.Hour,
+	}
+	attempts := 0
+	executeRequest := func(req *http.Request) (*http.Response, error) {
+		attempts++
+		header := make(http.Header)
+		header.Set("Retry-After", "0")
+		return &http.Response{
+			StatusCode: 503,
+			Header:     header,
+			Body:       reader_nop_close.New(bytes.NewBufferString("")),
+		}, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, "http://teamvault.example.com/api/secrets/key123/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := executeWithRetry(executeRequest, config, req.WithContext(ctx)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(attempts, Is(2)); err != nil {
+		t.Fatal(err)
+	}
+}
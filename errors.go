@@ -0,0 +1,139 @@
+package teamvault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	ErrNotFound              = errors.New("not found")
+	ErrUnauthorized          = errors.New("unauthorized")
+	ErrForbidden             = errors.New("forbidden")
+	ErrAccessRequestRequired = errors.New("access request required")
+	ErrServerUnavailable     = errors.New("server unavailable")
+)
+
+// RequestError is returned if a request to the Teamvault api fails.
+// errors.Is matches it against the Err* value of its Kind.
+type RequestError struct {
+	Method     string
+	Url        string
+	StatusCode int
+	Kind       error
+	Err        error
+}
+
+// NewRequestError classifies the failed request by its status code.
+func NewRequestError(method string, url string, statusCode int) *RequestError {
+	return &RequestError{
+		Method:     method,
+		Url:        url,
+		StatusCode: statusCode,
+		Kind:       kindOfStatus(statusCode),
+	}
+}
+
+func kindOfStatus(statusCode int) error {
+	switch {
+	case statusCode == http.StatusNotFound:
+		return ErrNotFound
+	case statusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case statusCode == http.StatusForbidden:
+		return ErrForbidden
+	case statusCode == http.StatusTooManyRequests || statusCode/100 == 5:
+		return ErrServerUnavailable
+	}
+	return nil
+}
+
+func (r *RequestError) Error() string {
+	var result string
+	if r.Err != nil {
+		result = fmt.Sprintf("request %s %s failed: %v", r.Method, r.Url, r.Err)
+	} else {
+		result = fmt.Sprintf("request %s %s failed with status: %d", r.Method, r.Url, r.StatusCode)
+	}
+	if r.Kind != nil {
+		result = fmt.Sprintf("%s (%v)", result, r.Kind)
+	}
+	return result
+}
+
+func (r *RequestError) Is(target error) bool {
+	return r.Kind != nil && target == r.Kind
+}
+
+func (r *RequestError) Unwrap() error {
+	return r.Err
+}
+
+const (
+	ExitOk                    = 0
+	ExitFailure               = 1
+	ExitNotFound              = 3
+	ExitUnauthorized          = 4
+	ExitForbidden             = 5
+	ExitAccessRequestRequired = 6
+	ExitServerUnavailable     = 7
+	ExitAborted               = 8
+)
+
+// ExitCode maps the error to the exit code of the commands.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOk
+	}
+	for e := err; e != nil; e = cause(e) {
+		switch {
+		case errors.Is(e, ErrNotFound):
+			return ExitNotFound
+		case errors.Is(e, ErrUnauthorized):
+			return ExitUnauthorized
+		case errors.Is(e, ErrForbidden):
+			return ExitForbidden
+		case errors.Is(e, ErrAccessRequestRequired):
+			return ExitAccessRequestRequired
+		case errors.Is(e, ErrServerUnavailable):
+			return ExitServerUnavailable
+		case errors.Is(e, context.Canceled), errors.Is(e, context.DeadlineExceeded):
+			return ExitAborted
+		}
+	}
+	return ExitFailure
+}
+
+// ErrorType names the kind of the error for logs and metrics.
+func ErrorType(err error) string {
+	switch ExitCode(err) {
+	case ExitOk:
+		return ""
+	case ExitNotFound:
+		return "not_found"
+	case ExitUnauthorized:
+		return "unauthorized"
+	case ExitForbidden:
+		return "forbidden"
+	case ExitAccessRequestRequired:
+		return "access_request_required"
+	case ExitServerUnavailable:
+		return "server_unavailable"
+	case ExitAborted:
+		return "aborted"
+	}
+	return "other"
+}
+
+// cause steps through errors wrapped by github.com/pkg/errors, which
+// errors.Is does not see through.
+func cause(err error) error {
+	for err != nil {
+		if c, ok := err.(interface{ Cause() error }); ok {
+			return c.Cause()
+		}
+		err = errors.Unwrap(err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package teamvault_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/bborbe/assert"
+	"github.com/bborbe/teamvault-utils"
+	pkg_errors "github.com/pkg/errors"
+)
+
+func TestRequestErrorIs(t *testing.T) {
+	var tests = []struct {
+		statusCode int
+		expected   error
+	}{
+		{404, teamvault.ErrNotFound},
+		{401, teamvault.ErrUnauthorized},
+		{403, teamvault.ErrForbidden},
+		{429, teamvault.ErrServerUnavailable},
+		{502, teamvault.ErrServerUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.statusCode), func(t *testing.T) {
+			err := fmt.Errorf("get password failed: %w", teamvault.NewRequestError("GET", "http://teamvault.example.com", tt.statusCode))
+			if err := AssertThat(errors.Is(err, tt.expected), Is(true)); err != nil {
+				t.Fatal(err)
+			}
+			var requestError *teamvault.RequestError
+			if err := AssertThat(errors.As(err, &requestError), Is(true)); err != nil {
+				t.Fatal(err)
+			}
+			if err := AssertThat(requestError.StatusCode, Is(tt.statusCode)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRequestErrorIsNot(t *testing.T) {
+	err := teamvault.NewRequestError("GET", "http://teamvault.example.com", 404)
+	if err := AssertThat(errors.Is(err, teamvault.ErrForbidden), Is(false)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	var tests = []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"nil", nil, teamvault.ExitOk},
+		{"other", fmt.Errorf("banana"), teamvault.ExitFailure},
+		{"not found", teamvault.NewRequestError("GET", "", 404), teamvault.ExitNotFound},
+		{"unauthorized", teamvault.NewRequestError("GET", "", 401), teamvault.ExitUnauthorized},
+		{"forbidden", teamvault.NewRequestError("GET", "", 403), teamvault.ExitForbidden},
+		{"access request", teamvault.ErrAccessRequestRequired, teamvault.ExitAccessRequestRequired},
+		{"unavailable", teamvault.NewRequestError("GET", "", 503), teamvault.ExitServerUnavailable},
+		{"deadline", context.DeadlineExceeded, teamvault.ExitAborted},
+		{"pkg errors", pkg_errors.Wrap(teamvault.NewRequestError("GET", "", 404), "get user failed"), teamvault.ExitNotFound},
+		{"mixed", fmt.Errorf("template: %w", pkg_errors.Wrapf(teamvault.NewRequestError("GET", "", 401), "get user failed")), teamvault.ExitUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := AssertThat(teamvault.ExitCode(tt.err), Is(tt.expected)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestErrorType(t *testing.T) {
+	var tests = []struct {
+		err      error
+		expected string
+	}{
+		{nil, ""},
+		{fmt.Errorf("banana"), "other"},
+		{teamvault.NewRequestError("GET", "", 404), "not_found"},
+		{teamvault.NewRequestError("GET", "", 429), "server_unavailable"},
+		{context.Canceled, "aborted"},
+	}
+	for _, tt := range tests {
+		if err := AssertThat(teamvault.ErrorType(tt.err), Is(tt.expected)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
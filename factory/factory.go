@@ -0,0 +1,396 @@
+package factory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/auth"
+	"github.com/bborbe/teamvault-utils/connector"
+	"github.com/bborbe/teamvault-utils/metrics"
+	"github.com/bborbe/teamvault-utils/transport"
+	"github.com/golang/glog"
+)
+
+// Base is implemented by the remote and the dummy connector.
+type Base interface {
+	teamvault.Connector
+	teamvault.Writer
+	teamvault.AccessRequester
+}
+
+// Factory builds the connector chain the commands use from the connector
+// section of the Teamvault config.
+type Factory struct {
+	config    *teamvault.TeamvaultConfig
+	staging   teamvault.Staging
+	layers    []teamvault.Layer
+	registry  *metrics.Registry
+	base      Base
+	fixture   string
+	replay    string
+	recorders map[string]*connector.Recorder
+}
+
+func New(config *teamvault.TeamvaultConfig, staging teamvault.Staging) *Factory {
+	f := new(Factory)
+	f.config = config
+	f.staging = staging
+	f.fixture = config.FixtureFile
+	if config.Connector != nil {
+		f.layers = append(f.layers, config.Connector.Layers...)
+	} else {
+		f.layers = DefaultLayers()
+	}
+	return f
+}
+
+// DefaultLayers are used without connector section in the config.
+func DefaultLayers() []teamvault.Layer {
+	return []teamvault.Layer{
+		{Type: teamvault.LayerTypeRetry, MaxRetries: connector.DefaultRetryConfig().MaxRetries},
+		{Type: teamvault.LayerTypeCache},
+	}
+}
+
+// Layers returns the layers of the chain, starting with the innermost.
+func (f *Factory) Layers() []teamvault.Layer {
+	return f.layers
+}
+
+// WithRetries sets max_retries of the retry layer, negative values keep the config.
+func (f *Factory) WithRetries(retries int) *Factory {
+	if retries < 0 {
+		return f
+	}
+	f.layer(teamvault.LayerTypeRetry, 0).MaxRetries = retries
+	return f
+}
+
+// WithDiskFallback adds the diskfallback layer if enabled.
+func (f *Factory) WithDiskFallback(enabled bool) *Factory {
+	if enabled {
+		f.layer(teamvault.LayerTypeDiskFallback, len(f.layers))
+	}
+	return f
+}
+
+// WithDiskFallbackKeyFile adds the diskfallback layer if missing and
+// encrypts its entries with the keyfile.
+func (f *Factory) WithDiskFallbackKeyFile(path string) *Factory {
+	if path != "" {
+		f.layer(teamvault.LayerTypeDiskFallback, len(f.layers)).KeyFile = path
+	}
+	return f
+}
+
+// WithFixture serves the secrets of the fixture file in staging instead
+// of dummy values, empty keeps the config.
+func (f *Factory) WithFixture(path string) *Factory {
+	if path != "" {
+		f.fixture = path
+	}
+	return f
+}
+
+// WithRecordFile adds the record layer next to the remote and writes the
+// cassette to the file on Close.
+func (f *Factory) WithRecordFile(path string, fake bool) *Factory {
+	if path != "" {
+		layer := f.layer(teamvault.LayerTypeRecord, f.afterRetry())
+		layer.File = path
+		layer.Fake = fake
+	}
+	return f
+}
+
+// WithReplayFile answers all calls from the cassette instead of Teamvault.
+func (f *Factory) WithReplayFile(path string) *Factory {
+	f.replay = path
+	return f
+}
+
+// WithEnv adds the env layer as outermost layer if enabled.
+func (f *Factory) WithEnv(enabled bool) *Factory {
+	if enabled {
+		f.layer(teamvault.LayerTypeEnv, len(f.layers))
+	}
+	return f
+}
+
+// WithEnvPattern adds the env layer if missing and names its variables
+// with the pattern, empty keeps the config.
+func (f *Factory) WithEnvPattern(pattern string) *Factory {
+	if pattern != "" {
+		f.layer(teamvault.LayerTypeEnv, len(f.layers)).Pattern = pattern
+	}
+	return f
+}
+
+// WithEnvStrict adds the env layer if missing and forbids asking Teamvault
+// for secrets not set in the environment.
+func (f *Factory) WithEnvStrict(strict bool) *Factory {
+	if strict {
+		f.layer(teamvault.LayerTypeEnv, len(f.layers)).Strict = true
+	}
+	return f
+}
+
+// WithMetricsFile adds the metrics layer next to the remote and writes the
+// metrics to the file on Close.
+func (f *Factory) WithMetricsFile(path string) *Factory {
+	if path != "" {
+		f.layer(teamvault.LayerTypeMetrics, f.afterRetry()).File = path
+	}
+	return f
+}
+
+// WithAccessRequestReason adds the accessrequest layer next to the remote.
+func (f *Factory) WithAccessRequestReason(reason teamvault.Reason) *Factory {
+	if reason != "" {
+		f.layer(teamvault.LayerTypeAccessRequest, f.afterRetry()).Reason = reason
+	}
+	return f
+}
+
+// layer returns the layer of the type, inserted at the position if missing.
+func (f *Factory) layer(layerType teamvault.LayerType, position int) *teamvault.Layer {
+	for i := range f.layers {
+		if f.layers[i].Type == layerType {
+			return &f.layers[i]
+		}
+	}
+	f.layers = append(f.layers, teamvault.Layer{})
+	copy(f.layers[position+1:], f.layers[position:])
+	f.layers[position] = teamvault.Layer{Type: layerType}
+	return &f.layers[position]
+}
+
+func (f *Factory) afterRetry() int {
+	if len(f.layers) > 0 && f.layers[0].Type == teamvault.LayerTypeRetry {
+		return 1
+	}
+	return 0
+}
+
+// Validate checks the types of the layers. Retry configures the remote
+// and must therefore be the first layer.
+func (f *Factory) Validate() error {
+	for i, layer := range f.layers {
+		switch layer.Type {
+		case teamvault.LayerTypeRetry:
+			if i != 0 {
+				return fmt.Errorf("layer retry must be the first layer")
+			}
+		case teamvault.LayerTypeAccessRequest:
+			if layer.Reason == "" {
+				return fmt.Errorf("layer accessrequest requires a reason")
+			}
+		case teamvault.LayerTypeRecord:
+			if layer.File == "" {
+				return fmt.Errorf("layer record requires a file")
+			}
+		case teamvault.LayerTypeCache, teamvault.LayerTypeDiskFallback, teamvault.LayerTypeMetrics, teamvault.LayerTypeEnv:
+		default:
+			return fmt.Errorf("unknown layer type %q", layer.Type)
+		}
+	}
+	return nil
+}
+
+// Base returns the remote connector or the replay of the cassette. In staging it returns the fixture,
+// answering unknown keys with dummy values unless fixture_strict is set,
+// or the dummy connector without fixture.
+func (f *Factory) Base() (Base, error) {
+	if f.base != nil {
+		return f.base, nil
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	if f.replay != "" {
+		cassette, err := connector.ReadCassette(f.replay)
+		if err != nil {
+			return nil, err
+		}
+		f.base = connector.NewReplay(cassette)
+		return f.base, nil
+	}
+	if f.staging {
+		if f.fixture == "" {
+			f.base = connector.NewDummy()
+			return f.base, nil
+		}
+		var fallback teamvault.Connector
+		if !f.config.FixtureStrict {
+			fallback = connector.NewDummy()
+		}
+		fixture, err := connector.ReadFixture(f.fixture, fallback)
+		if err != nil {
+			return nil, err
+		}
+		f.base = fixture
+		return f.base, nil
+	}
+	httpClient, err := transport.NewClient(f.config)
+	if err != nil {
+		return nil, err
+	}
+	remote := connector.NewRemote(httpClient.Do, f.config.Url, f.config.User, f.config.Password).
+		WithAuthenticator(auth.New(f.config)).
+		WithLimiter(connector.NewLimiter(connector.NewLimitConfig(f.config)))
+	for _, layer := range f.layers {
+		if layer.Type == teamvault.LayerTypeRetry {
+			retry := connector.DefaultRetryConfig()
+			retry.MaxRetries = layer.MaxRetries
+			remote = remote.WithRetry(retry)
+		}
+		if layer.Type == teamvault.LayerTypeCache && layer.TTL > 0 {
+			// the cache reads expired entries again, the remote must not
+			// answer them from its own records
+			remote = remote.WithTTL(layer.TTL.Duration())
+		}
+	}
+	f.base = remote
+	return f.base, nil
+}
+
+// Connector returns the base wrapped in all layers. In staging and replay
+// the layers diskfallback and accessrequest are left out.
+func (f *Factory) Connector() (teamvault.Connector, error) {
+	base, err := f.Base()
+	if err != nil {
+		return nil, err
+	}
+	offline := f.staging || f.replay != ""
+	var result teamvault.Connector = base
+	for _, layer := range f.layers {
+		switch layer.Type {
+		case teamvault.LayerTypeCache:
+			cache := connector.NewCache(result).
+				WithTTL(layer.TTL.Duration(), layer.StaleWhileRevalidate.Duration()).
+				WithRefreshTimeout(transport.RequestTimeout(f.config))
+			cache.Metrics = f.Metrics()
+			result = cache
+		case teamvault.LayerTypeDiskFallback:
+			if !offline {
+				diskFallback, err := newDiskFallback(layer)
+				if err != nil {
+					return nil, err
+				}
+				diskFallback.Connector = result
+				diskFallback.Metrics = f.Metrics()
+				result = diskFallback
+			}
+		case teamvault.LayerTypeMetrics:
+			result = connector.MetricsMiddleware(f.Metrics())(result)
+		case teamvault.LayerTypeRecord:
+			recorder := &connector.Recorder{Connector: result, Fake: layer.Fake}
+			if f.recorders == nil {
+				f.recorders = make(map[string]*connector.Recorder)
+			}
+			f.recorders[layer.File] = recorder
+			result = recorder
+		case teamvault.LayerTypeEnv:
+			result = &connector.Env{Connector: result, Pattern: layer.Pattern, Strict: layer.Strict}
+		case teamvault.LayerTypeAccessRequest:
+			if !offline {
+				result = &connector.AccessRequest{
+					Connector: result,
+					Requester: base,
+					Reason:    layer.Reason,
+				}
+			}
+		}
+		glog.V(4).Infof("connector layer %s added", layer.Type)
+	}
+	return result, nil
+}
+
+// DiskFallback returns the store of the diskfallback layer without
+// connector, e.g. to list or purge its entries. Without the layer the
+// defaults are used.
+func (f *Factory) DiskFallback() (*connector.DiskFallback, error) {
+	for _, layer := range f.layers {
+		if layer.Type == teamvault.LayerTypeDiskFallback {
+			return newDiskFallback(layer)
+		}
+	}
+	return newDiskFallback(teamvault.Layer{Type: teamvault.LayerTypeDiskFallback})
+}
+
+func newDiskFallback(layer teamvault.Layer) (*connector.DiskFallback, error) {
+	key, err := diskKey(layer)
+	if err != nil {
+		return nil, err
+	}
+	return &connector.DiskFallback{
+		Key:    key,
+		Dir:    layer.Dir,
+		MaxAge: layer.MaxAge.Duration(),
+	}, nil
+}
+
+// diskKey reads the key of the diskfallback layer, nil without key_file
+// and passphrase_command.
+func diskKey(layer teamvault.Layer) (*connector.DiskKey, error) {
+	if layer.KeyFile != "" {
+		return connector.ReadDiskKeyFile(layer.KeyFile)
+	}
+	if layer.PassphraseCommand == "" {
+		glog.V(1).Infof("diskfallback entries are not encrypted, set key_file or passphrase_command")
+		return nil, nil
+	}
+	_, passphrase, err := auth.Command("", layer.PassphraseCommand)(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("read diskfallback passphrase failed: %v", err)
+	}
+	dir := layer.Dir
+	if dir == "" {
+		dir = connector.DefaultDiskFallbackDir()
+	}
+	salt, err := connector.DiskKeySalt(dir)
+	if err != nil {
+		return nil, err
+	}
+	return connector.DeriveDiskKey(passphrase.String(), salt)
+}
+
+// Metrics returns the registry of the layers, nil without metrics layer.
+func (f *Factory) Metrics() *metrics.Registry {
+	if f.registry == nil && f.hasLayer(teamvault.LayerTypeMetrics) {
+		f.registry = metrics.NewRegistry()
+	}
+	return f.registry
+}
+
+func (f *Factory) hasLayer(layerType teamvault.LayerType) bool {
+	for _, layer := range f.layers {
+		if layer.Type == layerType {
+			return true
+		}
+	}
+	return false
+}
+
+// Close writes the metrics to the files of the metrics layers and the
+// cassettes of the record layers.
+func (f *Factory) Close() error {
+	for path, recorder := range f.recorders {
+		if err := recorder.Save(path); err != nil {
+			return fmt.Errorf("write cassette to %s failed: %v", path, err)
+		}
+	}
+	if f.registry == nil {
+		return nil
+	}
+	for _, layer := range f.layers {
+		if layer.Type != teamvault.LayerTypeMetrics || layer.File == "" {
+			continue
+		}
+		if err := f.registry.WriteFile(layer.File); err != nil {
+			return fmt.Errorf("write metrics to %s failed: %v", layer.File, err)
+		}
+	}
+	return nil
+}
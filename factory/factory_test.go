@@ -0,0 +1,284 @@
+package factory_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/bborbe/assert"
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/connector"
+	"github.com/bborbe/teamvault-utils/factory"
+	"github.com/bborbe/teamvault-utils/teamvaulttest"
+)
+
+func layerTypes(f *factory.Factory) string {
+	var result []string
+	for _, layer := range f.Layers() {
+		result = append(result, string(layer.Type))
+	}
+	return strings.Join(result, ",")
+}
+
+func TestDefaultLayers(t *testing.T) {
+	f := factory.New(&teamvault.TeamvaultConfig{}, true)
+	if err := AssertThat(layerTypes(f), Is("retry,cache")); err != nil {
+		t.Fatal(err)
+	}
+	c, err := f.Connector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, ok := c.(*connector.Cache)
+	if err := AssertThat(ok, Is(true)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.Connector.(*connector.Dummy); !ok {
+		t.Fatalf("dummy expected, got %T", cache.Connector)
+	}
+}
+
+func TestLayersFromConfig(t *testing.T) {
+	config, err := teamvault.ParseTeamvaultConfig([]byte(`{"connector":{"layers":[{"type":"cache"},{"type":"diskfallback"},{"type":"metrics"}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := factory.New(config, false)
+	if err := AssertThat(layerTypes(f), Is("cache,diskfallback,metrics")); err != nil {
+		t.Fatal(err)
+	}
+	c, err := f.Connector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, ok := c.(*connector.Metrics)
+	if !ok {
+		t.Fatalf("metrics expected, got %T", c)
+	}
+	d, ok := m.Connector.(*connector.DiskFallback)
+	if !ok {
+		t.Fatalf("diskfallback expected, got %T", m.Connector)
+	}
+	if _, ok := d.Connector.(*connector.Cache); !ok {
+		t.Fatalf("cache expected, got %T", d.Connector)
+	}
+	if err := AssertThat(f.Metrics(), NotNilValue()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheTTLFromConfig(t *testing.T) {
+	config, err := teamvault.ParseTeamvaultConfig([]byte(`{"connector":{"layers":[{"type":"cache","ttl":"5m","stale_while_revalidate":"1h"}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := factory.New(config, true).Connector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, ok := c.(*connector.Cache)
+	if !ok {
+		t.Fatalf("cache expected, got %T", c)
+	}
+	if err := AssertThat(cache.TTL, Is(5*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(cache.StaleWhileRevalidate, Is(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheTTLSeesRotation(t *testing.T) {
+	ctx := context.Background()
+	memory := connector.NewMemory()
+	key, err := memory.Create(ctx, teamvault.NewSecret{ContentType: teamvault.ContentTypePassword, Name: "db", Password: "S3CR3T"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := teamvaulttest.NewServer(memory).WithBasicAuth("user", "pass").Start()
+	defer server.Close()
+	c, err := factory.New(&teamvault.TeamvaultConfig{
+		Url:      teamvault.Url(server.URL),
+		User:     "user",
+		Password: "pass",
+		Connector: &teamvault.ConnectorConfig{
+			Layers: []teamvault.Layer{{Type: teamvault.LayerTypeCache, TTL: teamvault.Duration(10 * time.Millisecond)}},
+		},
+	}, false).Connector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Password(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := memory.Update(ctx, key, teamvault.SecretData{Password: "N3W"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	password, err := c.Password(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("N3W"))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStagingSkipsDiskFallback(t *testing.T) {
+	f := factory.New(&teamvault.TeamvaultConfig{}, true).WithDiskFallback(true)
+	c, err := f.Connector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.(*connector.Cache); !ok {
+		t.Fatalf("cache expected, got %T", c)
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	f := factory.New(&teamvault.TeamvaultConfig{
+		Connector: &teamvault.ConnectorConfig{
+			Layers: []teamvault.Layer{{Type: teamvault.LayerTypeCache}},
+		},
+	}, true).
+		WithRetries(5).
+		WithDiskFallback(true).
+		WithMetricsFile("/tmp/metrics.prom").
+		WithAccessRequestReason("deploy")
+	if err := AssertThat(layerTypes(f), Is("retry,accessrequest,metrics,cache,diskfallback")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(f.Layers()[0].MaxRetries, Is(5)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(f.WithRetries(-1).Layers()[0].MaxRetries, Is(5)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var tests = []struct {
+		name   string
+		layers []teamvault.Layer
+	}{
+		{"unknown", []teamvault.Layer{{Type: "banana"}}},
+		{"retry not first", []teamvault.Layer{{Type: teamvault.LayerTypeCache}, {Type: teamvault.LayerTypeRetry}}},
+		{"access request without reason", []teamvault.Layer{{Type: teamvault.LayerTypeAccessRequest}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := factory.New(&teamvault.TeamvaultConfig{Connector: &teamvault.ConnectorConfig{Layers: tt.layers}}, true)
+			if _, err := f.Connector(); err == nil {
+				t.Fatal("error expected")
+			}
+		})
+	}
+}
+
+func TestCloseWritesMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "factory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metrics.prom")
+	f := factory.New(&teamvault.TeamvaultConfig{}, true).WithMetricsFile(path)
+	c, err := f.Connector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Password(context.Background(), "key123"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `teamvault_connector_requests_total{method="password"} 1`) {
+		t.Fatalf("metrics missing in %s", content)
+	}
+}
+
+func TestStagingFixture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	if err := ioutil.WriteFile(path, []byte(`{"vLVLbm": {"password": "S3CR3T"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f := factory.New(&teamvault.TeamvaultConfig{FixtureStrict: true}, true).WithFixture(path)
+	c, err := f.Connector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, err := c.Password(context.Background(), "vLVLbm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("S3CR3T"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Password(context.Background(), "unknown"); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+	f := factory.New(&teamvault.TeamvaultConfig{}, true).WithRecordFile(cassette, false)
+	c, err := f.Connector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := c.Password(context.Background(), "vLVLbm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	c, err = factory.New(&teamvault.TeamvaultConfig{}, false).WithReplayFile(cassette).WithDiskFallback(true).Connector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, err := c.Password(context.Background(), "vLVLbm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(recorded)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Password(context.Background(), "other"); !errors.Is(err, connector.ErrNotRecorded) {
+		t.Fatalf("not recorded expected, got %v", err)
+	}
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("TV_key123_password", "S3CR3T")
+	f := factory.New(&teamvault.TeamvaultConfig{}, true).
+		WithEnvPattern("TV_{key}_{kind}").
+		WithEnvStrict(true)
+	if err := AssertThat(layerTypes(f), Is("retry,cache,env")); err != nil {
+		t.Fatal(err)
+	}
+	c, err := f.Connector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, err := c.Password(context.Background(), "key123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("S3CR3T"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.User(context.Background(), "key123"); !errors.Is(err, teamvault.ErrNotFound) {
+		t.Fatalf("not found expected, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package generator
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -13,7 +14,9 @@ import (
 )
 
 type configGenerator struct {
-	configParser parser.Parser
+	configParser      parser.Parser
+	prefetchConnector teamvault.Connector
+	prefetchWorkers   int
 }
 
 func New(
@@ -24,13 +27,31 @@ func New(
 	return c
 }
 
-func (c *configGenerator) Generate(sourceDirectory teamvault.SourceDirectory, targetDirectory teamvault.TargetDirectory) error {
+// WithPrefetch reads all values the templates reference with literal keys
+// through the connector before rendering. The connector should be the
+// cache the parser reads from.
+func (c *configGenerator) WithPrefetch(connector teamvault.Connector, workers int) *configGenerator {
+	c.prefetchConnector = connector
+	c.prefetchWorkers = workers
+	return c
+}
+
+func (c *configGenerator) Generate(ctx context.Context, sourceDirectory teamvault.SourceDirectory, targetDirectory teamvault.TargetDirectory) error {
 	glog.V(4).Infof("generate config from %s to %s", sourceDirectory.String(), targetDirectory.String())
+	if c.prefetchConnector != nil && c.prefetchWorkers > 0 {
+		if err := c.prefetch(ctx, sourceDirectory); err != nil {
+			return err
+		}
+	}
 	return filepath.Walk(sourceDirectory.String(), func(path string, info os.FileInfo, err error) error {
 		glog.V(4).Infof("generate path %s info %v", path, info)
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			glog.V(2).Infof("generate aborted: %v", err)
+			return err
+		}
 		target := fmt.Sprintf("%s%s", targetDirectory.String(), strings.TrimPrefix(path, sourceDirectory.String()))
 		glog.V(2).Infof("target: %s", target)
 		if info.IsDir() {
@@ -47,7 +68,7 @@ func (c *configGenerator) Generate(sourceDirectory teamvault.SourceDirectory, ta
 			glog.V(2).Infof("read file %s failed: %v", path, err)
 			return err
 		}
-		content, err = c.configParser.Parse(content)
+		content, err = c.configParser.Parse(ctx, content)
 		if err != nil {
 			glog.V(2).Infof("replace variables failed: %v", err)
 			return err
@@ -60,3 +81,51 @@ func (c *configGenerator) Generate(sourceDirectory teamvault.SourceDirectory, ta
 		return nil
 	})
 }
+
+func (c *configGenerator) prefetch(ctx context.Context, sourceDirectory teamvault.SourceDirectory) error {
+	references, err := References(ctx, sourceDirectory)
+	if err != nil {
+		return err
+	}
+	return parser.Prefetch(ctx, c.prefetchConnector, references, c.prefetchWorkers)
+}
+
+// References returns the distinct references with literal keys of all
+// templates in the directory. Broken templates are skipped.
+func References(ctx context.Context, sourceDirectory teamvault.SourceDirectory) ([]parser.Reference, error) {
+	found := make(map[parser.Reference]bool)
+	var references []parser.Reference
+	err := filepath.Walk(sourceDirectory.String(), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			glog.V(2).Infof("read file %s failed: %v", path, err)
+			return err
+		}
+		fileReferences, err := parser.References(content)
+		if err != nil {
+			// the render reports the broken template
+			glog.V(2).Infof("scan file %s failed: %v", path, err)
+			return nil
+		}
+		for _, reference := range fileReferences {
+			if !found[reference] {
+				found[reference] = true
+				references = append(references, reference)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return references, nil
+}
@@ -0,0 +1,8 @@
+//go:build !go1.21
+// +build !go1.21
+
+package teamvault
+
+// teamvault-utils needs Go 1.21 or newer, e.g. for context.WithoutCancel.
+// Older versions fail with undefined: requires_go_1_21_or_newer.
+var _ = requires_go_1_21_or_newer
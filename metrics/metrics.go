@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds of histograms in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Labels distinguish the series of a metric.
+type Labels map[string]string
+
+func (l Labels) String() string {
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s=%s", name, strconv.Quote(l[name]))
+	}
+	return strings.Join(parts, ",")
+}
+
+type metricType string
+
+const (
+	counterType   metricType = "counter"
+	histogramType metricType = "histogram"
+)
+
+type metric struct {
+	name   string
+	help   string
+	kind   metricType
+	series map[string]*series
+}
+
+type series struct {
+	labels  string
+	value   float64
+	buckets []uint64
+	count   uint64
+}
+
+// Registry collects counters and histograms. It is safe for concurrent use.
+// A nil registry discards everything.
+type Registry struct {
+	mux     sync.Mutex
+	metrics map[string]*metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]*metric),
+	}
+}
+
+// Inc adds one to the counter.
+func (r *Registry) Inc(name string, help string, labels Labels) {
+	if r == nil {
+		return
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.series(name, help, counterType, labels).value++
+}
+
+// Observe adds the value to the histogram.
+func (r *Registry) Observe(name string, help string, labels Labels, value float64) {
+	if r == nil {
+		return
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	s := r.series(name, help, histogramType, labels)
+	if s.buckets == nil {
+		s.buckets = make([]uint64, len(DefaultBuckets))
+	}
+	for i, bound := range DefaultBuckets {
+		if value <= bound {
+			s.buckets[i]++
+		}
+	}
+	s.value += value
+	s.count++
+}
+
+func (r *Registry) series(name string, help string, kind metricType, labels Labels) *series {
+	m, ok := r.metrics[name]
+	if !ok {
+		m = &metric{
+			name:   name,
+			help:   help,
+			kind:   kind,
+			series: make(map[string]*series),
+		}
+		r.metrics[name] = m
+	}
+	key := labels.String()
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{labels: key}
+		m.series[key] = s
+	}
+	return s
+}
+
+// WriteTo writes all metrics in the Prometheus text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	buf := &bytes.Buffer{}
+	if r != nil {
+		r.mux.Lock()
+		names := make([]string, 0, len(r.metrics))
+		for name := range r.metrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			r.metrics[name].write(buf)
+		}
+		r.mux.Unlock()
+	}
+	return buf.WriteTo(w)
+}
+
+func (m *metric) write(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", m.name, m.help)
+	fmt.Fprintf(buf, "# TYPE %s %s\n", m.name, m.kind)
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := m.series[key]
+		switch m.kind {
+		case counterType:
+			fmt.Fprintf(buf, "%s%s %s\n", m.name, braces(s.labels), format(s.value))
+		case histogramType:
+			for i, bound := range DefaultBuckets {
+				fmt.Fprintf(buf, "%s_bucket%s %d\n", m.name, braces(join(s.labels, "le="+strconv.Quote(format(bound)))), s.buckets[i])
+			}
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", m.name, braces(join(s.labels, `le="+Inf"`)), s.count)
+			fmt.Fprintf(buf, "%s_sum%s %s\n", m.name, braces(s.labels), format(s.value))
+			fmt.Fprintf(buf, "%s_count%s %d\n", m.name, braces(s.labels), s.count)
+		}
+	}
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func join(labels string, label string) string {
+	if labels == "" {
+		return label
+	}
+	return labels + "," + label
+}
+
+func format(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// ServeHTTP exposes the metrics for Prometheus to scrape.
+func (r *Registry) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.WriteTo(resp)
+}
+
+// WriteFile writes the metrics to the file, e.g. for the textfile collector
+// of the node exporter.
+func (r *Registry) WriteFile(path string) error {
+	buf := &bytes.Buffer{}
+	if _, err := r.WriteTo(buf); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
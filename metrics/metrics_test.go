@@ -0,0 +1,76 @@
+package metrics_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/bborbe/assert"
+	"github.com/bborbe/teamvault-utils/metrics"
+)
+
+func TestCounter(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Inc("requests_total", "Requests.", metrics.Labels{"method": "password", "result": "ok"})
+	registry.Inc("requests_total", "Requests.", metrics.Labels{"result": "ok", "method": "password"})
+	registry.Inc("requests_total", "Requests.", metrics.Labels{"method": "user", "result": "ok"})
+	buf := &bytes.Buffer{}
+	if _, err := registry.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{method="password",result="ok"} 2
+requests_total{method="user",result="ok"} 1
+`
+	if err := AssertThat(buf.String(), Is(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Observe("duration_seconds", "Duration.", nil, 0.02)
+	registry.Observe("duration_seconds", "Duration.", nil, 3)
+	buf := &bytes.Buffer{}
+	if _, err := registry.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`# TYPE duration_seconds histogram`,
+		`duration_seconds_bucket{le="0.01"} 0`,
+		`duration_seconds_bucket{le="0.025"} 1`,
+		`duration_seconds_bucket{le="5"} 2`,
+		`duration_seconds_bucket{le="+Inf"} 2`,
+		`duration_seconds_sum 3.02`,
+		`duration_seconds_count 2`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Fatalf("line %q missing in:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestNilRegistry(t *testing.T) {
+	var registry *metrics.Registry
+	registry.Inc("requests_total", "Requests.", nil)
+	registry.Observe("duration_seconds", "Duration.", nil, 1)
+	buf := &bytes.Buffer{}
+	if _, err := registry.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(buf.Len(), Is(0)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	registry := metrics.NewRegistry()
+	registry.Inc("requests_total", "Requests.", nil)
+	recorder := httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if err := AssertThat(recorder.Body.String(), Is("# HELP requests_total Requests.\n# TYPE requests_total counter\nrequests_total 1\n")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"os"
 	"strings"
+	"time"
 
 	io_util "github.com/bborbe/io/util"
 	"github.com/golang/glog"
@@ -24,6 +25,40 @@ func (t Key) String() string {
 	return string(t)
 }
 
+const keyRevisionSeparator = "@"
+
+// Split separates a pinned key like "vLVLbm@rKp1x5" into key and revision.
+// The revision is empty if the key is not pinned.
+func (t Key) Split() (Key, RevisionId) {
+	parts := strings.SplitN(t.String(), keyRevisionSeparator, 2)
+	if len(parts) < 2 {
+		return t, ""
+	}
+	return Key(parts[0]), RevisionId(parts[1])
+}
+
+// WithRevision pins the key to the given revision.
+func (t Key) WithRevision(revision RevisionId) Key {
+	key, _ := t.Split()
+	if revision == "" {
+		return key
+	}
+	return Key(key.String() + keyRevisionSeparator + revision.String())
+}
+
+type RevisionId string
+
+func (r RevisionId) String() string {
+	return string(r)
+}
+
+// Revision of a secret as listed by Teamvault.
+type Revision struct {
+	Id      RevisionId
+	Created time.Time
+	SetBy   User
+}
+
 type SourceDirectory string
 
 func (s SourceDirectory) String() string {
@@ -66,6 +101,14 @@ func (t TeamvaultCurrentRevision) String() string {
 	return string(t)
 }
 
+func (t TeamvaultCurrentRevision) Id() (RevisionId, error) {
+	parts := strings.Split(t.String(), "/")
+	if len(parts) < 3 {
+		return "", fmt.Errorf("parse revision form api-url failed")
+	}
+	return RevisionId(parts[len(parts)-2]), nil
+}
+
 type File string
 
 func (t File) String() string {
@@ -76,10 +119,349 @@ func (t File) Content() ([]byte, error) {
 	return base64.StdEncoding.DecodeString(t.String())
 }
 
+type ContentType string
+
+const (
+	ContentTypePassword   ContentType = "password"
+	ContentTypeFile       ContentType = "file"
+	ContentTypeCreditCard ContentType = "cc"
+)
+
+func (c ContentType) String() string {
+	return string(c)
+}
+
+type AccessPolicy string
+
+const (
+	AccessPolicyRequest  AccessPolicy = "request"
+	AccessPolicyEveryone AccessPolicy = "everyone"
+	AccessPolicyHidden   AccessPolicy = "hidden"
+)
+
+func (a AccessPolicy) String() string {
+	return string(a)
+}
+
+type Name string
+
+func (n Name) String() string {
+	return string(n)
+}
+
+type Description string
+
+func (d Description) String() string {
+	return string(d)
+}
+
+type Filename string
+
+func (f Filename) String() string {
+	return string(f)
+}
+
+// NewSecret describes a secret to create in Teamvault.
+type NewSecret struct {
+	ContentType  ContentType
+	Name         Name
+	Description  Description
+	User         User
+	Url          Url
+	Filename     Filename
+	AccessPolicy AccessPolicy
+	Password     Password
+	File         File
+	CreditCard   *CreditCard
+}
+
+// Data returns the content of the first revision.
+func (n NewSecret) Data() SecretData {
+	return SecretData{
+		Password:   n.Password,
+		File:       n.File,
+		CreditCard: n.CreditCard,
+	}
+}
+
+func (n NewSecret) Validate() error {
+	if n.Name == "" {
+		return fmt.Errorf("name missing")
+	}
+	switch n.AccessPolicy {
+	case "", AccessPolicyRequest, AccessPolicyEveryone, AccessPolicyHidden:
+	default:
+		return fmt.Errorf("unknown access policy %v", n.AccessPolicy)
+	}
+	switch n.ContentType {
+	case ContentTypePassword:
+		if n.Password == "" {
+			return fmt.Errorf("password missing")
+		}
+		if n.File != "" || n.CreditCard != nil {
+			return fmt.Errorf("only password allowed for content type %v", n.ContentType)
+		}
+	case ContentTypeFile:
+		if n.File == "" {
+			return fmt.Errorf("file missing")
+		}
+		if n.Password != "" || n.CreditCard != nil {
+			return fmt.Errorf("only file allowed for content type %v", n.ContentType)
+		}
+	case ContentTypeCreditCard:
+		if n.CreditCard == nil {
+			return fmt.Errorf("credit card missing")
+		}
+		if n.File != "" {
+			return fmt.Errorf("file not allowed for content type %v", n.ContentType)
+		}
+		return n.CreditCard.Validate()
+	default:
+		return fmt.Errorf("unknown content type %v", n.ContentType)
+	}
+	return nil
+}
+
+// SecretData is the content of a secret revision.
+type SecretData struct {
+	Password   Password
+	File       File
+	CreditCard *CreditCard
+}
+
+func (s SecretData) Validate() error {
+	if s.File != "" && (s.Password != "" || s.CreditCard != nil) {
+		return fmt.Errorf("file can not be combined with password or credit card")
+	}
+	if s.Password == "" && s.File == "" && s.CreditCard == nil {
+		return fmt.Errorf("password, file or credit card required")
+	}
+	if s.CreditCard != nil {
+		return s.CreditCard.Validate()
+	}
+	return nil
+}
+
+// CreditCard is the data of a secret with content type cc.
+type CreditCard struct {
+	Holder          string `json:"holder" yaml:"holder"`
+	Number          string `json:"number" yaml:"number"`
+	ExpirationMonth string `json:"expiration_month" yaml:"expiration_month"`
+	ExpirationYear  string `json:"expiration_year" yaml:"expiration_year"`
+	SecurityCode    string `json:"security_code" yaml:"security_code"`
+}
+
+// Expiration returns the expiration date formatted as MM/YYYY.
+func (c CreditCard) Expiration() string {
+	return fmt.Sprintf("%02s/%s", c.ExpirationMonth, c.ExpirationYear)
+}
+
+// Field returns the value of the field with the given name.
+func (c CreditCard) Field(name string) (string, error) {
+	switch name {
+	case "holder":
+		return c.Holder, nil
+	case "number":
+		return c.Number, nil
+	case "expiration":
+		return c.Expiration(), nil
+	case "expiration_month":
+		return c.ExpirationMonth, nil
+	case "expiration_year":
+		return c.ExpirationYear, nil
+	case "security_code":
+		return c.SecurityCode, nil
+	}
+	return "", fmt.Errorf("unknown credit card field %s", name)
+}
+
+func (c CreditCard) Validate() error {
+	if c.Number == "" {
+		return fmt.Errorf("credit card number missing")
+	}
+	return nil
+}
+
+type SecretStatus string
+
+const (
+	SecretStatusOk            SecretStatus = "ok"
+	SecretStatusNeedsChanging SecretStatus = "needs_changing"
+	SecretStatusDeleted       SecretStatus = "deleted"
+)
+
+func (s SecretStatus) String() string {
+	return string(s)
+}
+
+// Secret holds the metadata Teamvault stores for a secret.
+type Secret struct {
+	Key                  Key                      `json:"key"`
+	ApiUrl               TeamvaultApiUrl          `json:"api_url"`
+	WebUrl               Url                      `json:"web_url"`
+	Name                 Name                     `json:"name"`
+	Description          Description              `json:"description"`
+	ContentType          ContentType              `json:"content_type"`
+	Filename             Filename                 `json:"filename"`
+	User                 User                     `json:"username"`
+	Url                  Url                      `json:"url"`
+	Status               SecretStatus             `json:"status"`
+	AccessPolicy         AccessPolicy             `json:"access_policy"`
+	NeedsChangingOnLeave bool                     `json:"needs_changing_on_leave"`
+	CurrentRevision      TeamvaultCurrentRevision `json:"current_revision"`
+	Created              time.Time                `json:"created"`
+	CreatedBy            User                     `json:"created_by"`
+	LastRead             time.Time                `json:"last_read"`
+}
+
+// SearchOptions narrow down a secret search. Name is passed to the
+// Teamvault full text search, all other fields filter its results.
+type SearchOptions struct {
+	Name        string
+	ExactName   bool
+	ContentType ContentType
+	Status      SecretStatus
+	Limit       int
+}
+
+// Matches reports whether the secret passes the filters of the options.
+func (s SearchOptions) Matches(secret Secret) bool {
+	if s.ExactName && secret.Name.String() != s.Name {
+		return false
+	}
+	if s.ContentType != "" && secret.ContentType != s.ContentType {
+		return false
+	}
+	if s.Status != "" && secret.Status != s.Status {
+		return false
+	}
+	return true
+}
+
+// Full reports whether the result list reached the limit.
+func (s SearchOptions) Full(results []Secret) bool {
+	return s.Limit > 0 && len(results) >= s.Limit
+}
+
+// TeamvaultConfig is read from ~/.teamvault.json. Instead of a plaintext
+// password it may hold an api token, a command printing the password or
+// the path of a netrc file. The remaining fields configure the http client
+// and limit the requests.
 type TeamvaultConfig struct {
-	Url      Url      `json:"url"`
-	User     User     `json:"user"`
-	Password Password `json:"pass"`
+	Url                   Url              `json:"url"`
+	User                  User             `json:"user"`
+	Password              Password         `json:"pass"`
+	Token                 Token            `json:"token,omitempty"`
+	PassCommand           string           `json:"pass_command,omitempty"`
+	Netrc                 string           `json:"netrc,omitempty"`
+	CaFile                string           `json:"ca_file,omitempty"`
+	CertFile              string           `json:"cert_file,omitempty"`
+	KeyFile               string           `json:"key_file,omitempty"`
+	InsecureSkipVerify    bool             `json:"insecure_skip_verify,omitempty"`
+	Proxy                 string           `json:"proxy,omitempty"`
+	RequestTimeout        Duration         `json:"request_timeout,omitempty"`
+	DialTimeout           Duration         `json:"dial_timeout,omitempty"`
+	TLSHandshakeTimeout   Duration         `json:"tls_handshake_timeout,omitempty"`
+	IdleConnTimeout       Duration         `json:"idle_conn_timeout,omitempty"`
+	MaxIdleConns          int              `json:"max_idle_conns,omitempty"`
+	MaxIdleConnsPerHost   int              `json:"max_idle_conns_per_host,omitempty"`
+	MaxConnsPerHost       int              `json:"max_conns_per_host,omitempty"`
+	RateLimit             float64          `json:"rate_limit,omitempty"`
+	RateLimitBurst        int              `json:"rate_limit_burst,omitempty"`
+	MaxConcurrentRequests int              `json:"max_concurrent_requests,omitempty"`
+	RateLimitLock         bool             `json:"rate_limit_lock,omitempty"`
+	Connector             *ConnectorConfig `json:"connector,omitempty"`
+	FixtureFile           string           `json:"fixture_file,omitempty"`
+	FixtureStrict         bool             `json:"fixture_strict,omitempty"`
+}
+
+// ConnectorConfig lists the layers wrapped around the remote connector,
+// starting with the innermost.
+type ConnectorConfig struct {
+	Layers []Layer `json:"layers"`
+}
+
+type LayerType string
+
+const (
+	LayerTypeRetry         LayerType = "retry"
+	LayerTypeCache         LayerType = "cache"
+	LayerTypeDiskFallback  LayerType = "diskfallback"
+	LayerTypeMetrics       LayerType = "metrics"
+	LayerTypeAccessRequest LayerType = "accessrequest"
+	LayerTypeRecord        LayerType = "record"
+	LayerTypeEnv           LayerType = "env"
+)
+
+// Layer of the connector chain. Options apply only to the named type.
+type Layer struct {
+	Type LayerType `json:"type"`
+	// MaxRetries of retry
+	MaxRetries int `json:"max_retries,omitempty"`
+	// File metrics or the cassette of record are written to at exit
+	File string `json:"file,omitempty"`
+	// Fake replaces secret values by dummy values in the cassette of record
+	Fake bool `json:"fake,omitempty"`
+	// Reason access is requested with
+	Reason Reason `json:"reason,omitempty"`
+	// TTL of cache entries, zero keeps them forever
+	TTL Duration `json:"ttl,omitempty"`
+	// StaleWhileRevalidate returns expired cache entries this long after the ttl
+	StaleWhileRevalidate Duration `json:"stale_while_revalidate,omitempty"`
+	// KeyFile diskfallback entries are encrypted with
+	KeyFile string `json:"key_file,omitempty"`
+	// PassphraseCommand prints the passphrase diskfallback entries are encrypted with
+	PassphraseCommand string `json:"passphrase_command,omitempty"`
+	// Dir diskfallback entries are stored in
+	Dir string `json:"dir,omitempty"`
+	// MaxAge after which diskfallback entries are refused, zero serves them forever
+	MaxAge Duration `json:"max_age,omitempty"`
+	// Pattern names the variables of env, e.g. "TEAMVAULT_{key}_{KIND}"
+	Pattern string `json:"pattern,omitempty"`
+	// Strict fails calls env can not answer instead of asking Teamvault
+	Strict bool `json:"strict,omitempty"`
+}
+
+// Duration is written as "30s" or "1m30s" in json.
+type Duration time.Duration
+
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(content []byte) error {
+	var value string
+	if err := json.Unmarshal(content, &value); err != nil {
+		return fmt.Errorf("parse duration %s failed: %v", content, err)
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// Reason tells the owners of a secret why access is requested.
+type Reason string
+
+func (r Reason) String() string {
+	return string(r)
+}
+
+// Token is an api token sent as bearer authorization.
+type Token string
+
+func (t Token) String() string {
+	return string(t)
 }
 
 type TeamvaultConfigPath string
@@ -2,6 +2,7 @@ package teamvault_test
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/bborbe/assert"
 	"github.com/bborbe/teamvault-utils"
@@ -43,3 +44,124 @@ func TestTeamvaultApiUrlKey(t *testing.T) {
 		})
 	}
 }
+
+func TestKeySplit(t *testing.T) {
+	var tests = []struct {
+		name             string
+		key              teamvault.Key
+		expectedKey      teamvault.Key
+		expectedRevision teamvault.RevisionId
+	}{
+		{"empty", "", "", ""},
+		{"plain", "vLVLbm", "vLVLbm", ""},
+		{"pinned", "vLVLbm@rKp1x5", "vLVLbm", "rKp1x5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, revision := tt.key.Split()
+			if key != tt.expectedKey {
+				t.Fatalf("expected %v got %v", tt.expectedKey, key)
+			}
+			if revision != tt.expectedRevision {
+				t.Fatalf("expected %v got %v", tt.expectedRevision, revision)
+			}
+		})
+	}
+}
+
+func TestKeyWithRevision(t *testing.T) {
+	key := teamvault.Key("vLVLbm@old").WithRevision("rKp1x5")
+	if err := AssertThat(key.String(), Is("vLVLbm@rKp1x5")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTeamvaultCurrentRevisionId(t *testing.T) {
+	revision := teamvault.TeamvaultCurrentRevision("https://teamvault.example.com/api/secret-revisions/rKp1x5/")
+	id, err := revision.Id()
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(id.String(), Is("rKp1x5")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreditCardField(t *testing.T) {
+	creditCard := teamvault.CreditCard{
+		Holder:          "Ben",
+		Number:          "4111111111111111",
+		ExpirationMonth: "3",
+		ExpirationYear:  "2030",
+		SecurityCode:    "123",
+	}
+	var tests = []struct {
+		field         string
+		expectedError bool
+		expectedValue string
+	}{
+		{"holder", false, "Ben"},
+		{"number", false, "4111111111111111"},
+		{"expiration", false, "03/2030"},
+		{"expiration_month", false, "3"},
+		{"expiration_year", false, "2030"},
+		{"security_code", false, "123"},
+		{"pin", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			value, err := creditCard.Field(tt.field)
+			if (err != nil) != tt.expectedError {
+				t.Fatalf("expected error %v got %v", tt.expectedError, err)
+			}
+			if value != tt.expectedValue {
+				t.Fatalf("expected %v got %v", tt.expectedValue, value)
+			}
+		})
+	}
+}
+
+func TestNewSecretValidate(t *testing.T) {
+	var tests = []struct {
+		name          string
+		secret        teamvault.NewSecret
+		expectedError bool
+	}{
+		{"password", teamvault.NewSecret{Name: "db", ContentType: teamvault.ContentTypePassword, Password: "S3CR3T"}, false},
+		{"empty password", teamvault.NewSecret{Name: "db", ContentType: teamvault.ContentTypePassword}, true},
+		{"empty password with url", teamvault.NewSecret{Name: "db", ContentType: teamvault.ContentTypePassword, Url: "https://db.example.com"}, true},
+		{"file", teamvault.NewSecret{Name: "cert", ContentType: teamvault.ContentTypeFile, File: "Y2VydA=="}, false},
+		{"password with file", teamvault.NewSecret{Name: "cert", ContentType: teamvault.ContentTypeFile, File: "Y2VydA==", Password: "S3CR3T"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.secret.Validate()
+			if (err != nil) != tt.expectedError {
+				t.Fatalf("expected error %v got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestParseTeamvaultConfigTransport(t *testing.T) {
+	config, err := teamvault.ParseTeamvaultConfig([]byte(`{"url":"https://teamvault.example.com","ca_file":"~/ca.pem","request_timeout":"1m30s","max_conns_per_host":4}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(config.CaFile, Is("~/ca.pem")); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(config.RequestTimeout.Duration(), Is(90*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(config.MaxConnsPerHost, Is(4)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseTeamvaultConfigInvalidDuration(t *testing.T) {
+	_, err := teamvault.ParseTeamvaultConfig([]byte(`{"request_timeout":30}`))
+	if err := AssertThat(err, NotNilValue()); err != nil {
+		t.Fatal(err)
+	}
+}
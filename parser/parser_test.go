@@ -1,12 +1,14 @@
 package parser
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"testing"
 
 	. "github.com/bborbe/assert"
+	"github.com/bborbe/teamvault-utils"
 	"github.com/bborbe/teamvault-utils/connector"
 )
 
@@ -14,7 +16,7 @@ func TestParseContentWithoutPlaceholder(t *testing.T) {
 	teamvaultConnector := connector.NewDummy()
 	teamvaultParser := New(teamvaultConnector)
 	contentWithoutPlaceholder := []byte("hello world")
-	resultContent, err := teamvaultParser.Parse(contentWithoutPlaceholder)
+	resultContent, err := teamvaultParser.Parse(context.Background(), contentWithoutPlaceholder)
 	if err := AssertThat(err, NilValue()); err != nil {
 		t.Fatal(err)
 	}
@@ -29,7 +31,7 @@ func TestParseContentWithoutPlaceholder(t *testing.T) {
 func TestParseTeamvaultUsername(t *testing.T) {
 	teamvaultConnector := connector.NewDummy()
 	teamvaultParser := New(teamvaultConnector)
-	resultContent, err := teamvaultParser.Parse([]byte(`{{ "asdf" | teamvaultUser }}`))
+	resultContent, err := teamvaultParser.Parse(context.Background(), []byte(`{{ "asdf" | teamvaultUser }}`))
 	if err := AssertThat(err, NilValue()); err != nil {
 		t.Fatal(err)
 	}
@@ -44,7 +46,7 @@ func TestParseTeamvaultUsername(t *testing.T) {
 func TestParseTeamvaultPassword(t *testing.T) {
 	teamvaultConnector := connector.NewDummy()
 	teamvaultParser := New(teamvaultConnector)
-	resultContent, err := teamvaultParser.Parse([]byte(`{{ "asdf" | teamvaultPassword }}`))
+	resultContent, err := teamvaultParser.Parse(context.Background(), []byte(`{{ "asdf" | teamvaultPassword }}`))
 	if err := AssertThat(err, NilValue()); err != nil {
 		t.Fatal(err)
 	}
@@ -59,7 +61,7 @@ func TestParseTeamvaultPassword(t *testing.T) {
 func TestParseTeamvaultUrl(t *testing.T) {
 	teamvaultConnector := connector.NewDummy()
 	teamvaultParser := New(teamvaultConnector)
-	resultContent, err := teamvaultParser.Parse([]byte(`{{ "asdf" | teamvaultUrl}}`))
+	resultContent, err := teamvaultParser.Parse(context.Background(), []byte(`{{ "asdf" | teamvaultUrl}}`))
 	if err := AssertThat(err, NilValue()); err != nil {
 		t.Fatal(err)
 	}
@@ -74,7 +76,7 @@ func TestParseTeamvaultUrl(t *testing.T) {
 func TestParseTeamvaultFile(t *testing.T) {
 	teamvaultConnector := connector.NewDummy()
 	teamvaultParser := New(teamvaultConnector)
-	resultContent, err := teamvaultParser.Parse([]byte(`{{ "asdf" | teamvaultFile}}`))
+	resultContent, err := teamvaultParser.Parse(context.Background(), []byte(`{{ "asdf" | teamvaultFile}}`))
 	if err := AssertThat(err, NilValue()); err != nil {
 		t.Fatal(err)
 	}
@@ -89,7 +91,7 @@ func TestParseTeamvaultFile(t *testing.T) {
 func TestParseTeamvaultFileBase64(t *testing.T) {
 	teamvaultConnector := connector.NewDummy()
 	teamvaultParser := New(teamvaultConnector)
-	resultContent, err := teamvaultParser.Parse([]byte(`{{ "asdf" | teamvaultFileBase64}}`))
+	resultContent, err := teamvaultParser.Parse(context.Background(), []byte(`{{ "asdf" | teamvaultFileBase64}}`))
 	if err := AssertThat(err, NilValue()); err != nil {
 		t.Fatal(err)
 	}
@@ -104,7 +106,7 @@ func TestParseTeamvaultFileBase64(t *testing.T) {
 func TestParseBase64(t *testing.T) {
 	teamvaultConnector := connector.NewDummy()
 	teamvaultParser := New(teamvaultConnector)
-	resultContent, err := teamvaultParser.Parse([]byte(`{{ "abc" | base64}}`))
+	resultContent, err := teamvaultParser.Parse(context.Background(), []byte(`{{ "abc" | base64}}`))
 	if err := AssertThat(err, NilValue()); err != nil {
 		t.Fatal(err)
 	}
@@ -120,7 +122,7 @@ func TestParseEnv(t *testing.T) {
 	teamvaultConnector := connector.NewDummy()
 	teamvaultParser := New(teamvaultConnector)
 	os.Setenv("testEnv", "hello")
-	resultContent, err := teamvaultParser.Parse([]byte(`{{ "testEnv" | env}}`))
+	resultContent, err := teamvaultParser.Parse(context.Background(), []byte(`{{ "testEnv" | env}}`))
 	if err := AssertThat(err, NilValue()); err != nil {
 		t.Fatal(err)
 	}
@@ -135,7 +137,7 @@ func TestParseEnv(t *testing.T) {
 func TestParseTeamvaultHtpasswd(t *testing.T) {
 	teamvaultConnector := connector.NewDummy()
 	teamvaultParser := New(teamvaultConnector)
-	resultContent, err := teamvaultParser.Parse([]byte(`{{ "abc" | teamvaultHtpasswd}}`))
+	resultContent, err := teamvaultParser.Parse(context.Background(), []byte(`{{ "abc" | teamvaultHtpasswd}}`))
 	if err := AssertThat(err, NilValue()); err != nil {
 		t.Fatal(err)
 	}
@@ -160,7 +162,7 @@ func TestParseFile(t *testing.T) {
 
 	teamvaultConnector := connector.NewDummy()
 	teamvaultParser := New(teamvaultConnector)
-	resultContent, err := teamvaultParser.Parse([]byte(fmt.Sprintf(`{{ "%s" | readfile }}`, path)))
+	resultContent, err := teamvaultParser.Parse(context.Background(), []byte(fmt.Sprintf(`{{ "%s" | readfile }}`, path)))
 	if err := AssertThat(err, NilValue()); err != nil {
 		t.Fatal(err)
 	}
@@ -171,3 +173,54 @@ func TestParseFile(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestParseTeamvaultPasswordPinnedRevision(t *testing.T) {
+	ctx := context.Background()
+	teamvaultConnector := connector.NewMemory()
+	key, err := teamvaultConnector.Create(ctx, teamvault.NewSecret{
+		ContentType: teamvault.ContentTypePassword,
+		Name:        "My Service",
+		Password:    "old",
+	})
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := teamvaultConnector.Update(ctx, key, teamvault.SecretData{Password: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	teamvaultParser := New(teamvaultConnector)
+	resultContent, err := teamvaultParser.Parse(ctx, []byte(fmt.Sprintf(`{{ "%s@%sr1" | teamvaultPassword }} {{ "%s" | teamvaultPassword }}`, key, key, key)))
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(string(resultContent), Is("old new")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseTeamvaultCreditCard(t *testing.T) {
+	ctx := context.Background()
+	teamvaultConnector := connector.NewMemory()
+	key, err := teamvaultConnector.Create(ctx, teamvault.NewSecret{
+		ContentType: teamvault.ContentTypeCreditCard,
+		Name:        "Billing",
+		CreditCard: &teamvault.CreditCard{
+			Holder:          "Ben",
+			Number:          "4111111111111111",
+			ExpirationMonth: "3",
+			ExpirationYear:  "2030",
+			SecurityCode:    "123",
+		},
+	})
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	teamvaultParser := New(teamvaultConnector)
+	resultContent, err := teamvaultParser.Parse(ctx, []byte(fmt.Sprintf(`{{ "%s" | teamvaultCreditCardHolder }} {{ "%s" | teamvaultCreditCardNumber }} {{ "%s" | teamvaultCreditCardExpiration }} {{ "%s" | teamvaultCreditCardSecurityCode }}`, key, key, key, key)))
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(string(resultContent), Is("Ben 4111111111111111 03/2030 123")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,180 @@
+package parser
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/bborbe/teamvault-utils"
+	"github.com/golang/glog"
+)
+
+// Kind is the part of a secret a template function reads.
+type Kind string
+
+const (
+	KindPassword   Kind = "password"
+	KindUser       Kind = "user"
+	KindUrl        Kind = "url"
+	KindFile       Kind = "file"
+	KindCreditCard Kind = "creditcard"
+)
+
+// kindsOfFunc lists what each template function reads from Teamvault.
+var kindsOfFunc = map[string][]Kind{
+	"teamvaultUser":                      {KindUser},
+	"teamvaultPassword":                  {KindPassword},
+	"teamvaultHtpasswd":                  {KindPassword, KindUser},
+	"teamvaultUrl":                       {KindUrl},
+	"teamvaultFile":                      {KindFile},
+	"teamvaultFileBase64":                {KindFile},
+	"teamvaultCreditCardHolder":          {KindCreditCard},
+	"teamvaultCreditCardNumber":          {KindCreditCard},
+	"teamvaultCreditCardExpiration":      {KindCreditCard},
+	"teamvaultCreditCardExpirationMonth": {KindCreditCard},
+	"teamvaultCreditCardExpirationYear":  {KindCreditCard},
+	"teamvaultCreditCardSecurityCode":    {KindCreditCard},
+}
+
+// Reference is a value of a secret a template reads.
+type Reference struct {
+	Kind Kind
+	Key  teamvault.Key
+}
+
+// References scans the template for teamvault functions called with a
+// literal key. Keys computed at render time are not found.
+func References(content []byte) ([]Reference, error) {
+	t, err := template.New("config").Funcs(new(configParser).createFuncMap(context.Background())).Parse(string(content))
+	if err != nil {
+		glog.V(2).Infof("parse config failed: %v", err)
+		return nil, err
+	}
+	found := make(map[Reference]bool)
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil {
+			walk(tmpl.Tree.Root, found)
+		}
+	}
+	result := make([]Reference, 0, len(found))
+	for reference := range found {
+		result = append(result, reference)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Key != result[j].Key {
+			return result[i].Key < result[j].Key
+		}
+		return result[i].Kind < result[j].Kind
+	})
+	return result, nil
+}
+
+func walk(node parse.Node, found map[Reference]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walk(child, found)
+		}
+	case *parse.ActionNode:
+		walk(n.Pipe, found)
+	case *parse.IfNode:
+		walk(n.Pipe, found)
+		walk(n.List, found)
+		walk(n.ElseList, found)
+	case *parse.RangeNode:
+		walk(n.Pipe, found)
+		walk(n.List, found)
+		walk(n.ElseList, found)
+	case *parse.WithNode:
+		walk(n.Pipe, found)
+		walk(n.List, found)
+		walk(n.ElseList, found)
+	case *parse.TemplateNode:
+		walk(n.Pipe, found)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for i, cmd := range n.Cmds {
+			for _, arg := range cmd.Args {
+				walk(arg, found)
+			}
+			identifier, ok := cmd.Args[0].(*parse.IdentifierNode)
+			if !ok {
+				continue
+			}
+			kinds, ok := kindsOfFunc[identifier.Ident]
+			if !ok {
+				continue
+			}
+			var key *parse.StringNode
+			if len(cmd.Args) > 1 {
+				// {{ teamvaultPassword "key" }}
+				key, _ = cmd.Args[1].(*parse.StringNode)
+			} else if i > 0 && len(n.Cmds[i-1].Args) == 1 {
+				// {{ "key" | teamvaultPassword }}
+				key, _ = n.Cmds[i-1].Args[0].(*parse.StringNode)
+			}
+			if key == nil {
+				continue
+			}
+			for _, kind := range kinds {
+				found[Reference{Kind: kind, Key: teamvault.Key(key.Text)}] = true
+			}
+		}
+	}
+}
+
+// Prefetch reads the referenced values with the given number of workers,
+// so a cache in front of the connector serves the render. Failures are
+// left to the render to report, only the end of the context stops it.
+func Prefetch(ctx context.Context, connector teamvault.Connector, references []Reference, workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+	glog.V(2).Infof("prefetch %d values with %d workers", len(references), workers)
+	ch := make(chan Reference)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for reference := range ch {
+				if err := fetch(ctx, connector, reference); err != nil {
+					glog.V(2).Infof("prefetch %s of %v failed: %v", reference.Kind, reference.Key, err)
+				}
+			}
+		}()
+	}
+	for _, reference := range references {
+		if ctx.Err() != nil {
+			break
+		}
+		ch <- reference
+	}
+	close(ch)
+	wg.Wait()
+	return ctx.Err()
+}
+
+func fetch(ctx context.Context, connector teamvault.Connector, reference Reference) error {
+	var err error
+	switch reference.Kind {
+	case KindPassword:
+		_, err = connector.Password(ctx, reference.Key)
+	case KindUser:
+		_, err = connector.User(ctx, reference.Key)
+	case KindUrl:
+		_, err = connector.Url(ctx, reference.Key)
+	case KindFile:
+		_, err = connector.File(ctx, reference.Key)
+	case KindCreditCard:
+		_, err = connector.CreditCard(ctx, reference.Key)
+	}
+	return err
+}
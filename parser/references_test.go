@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/bborbe/assert"
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/connector"
+)
+
+func TestReferences(t *testing.T) {
+	references, err := References([]byte(`
+user: {{ "k1" | teamvaultUser }}
+pass: {{ teamvaultPassword "k2" }}
+{{ if env "DEBUG" }}htpasswd: {{ "k3" | teamvaultHtpasswd | indent 2 }}{{ end }}
+{{ range $i := "ab" }}{{ end }}
+{{ define "sub" }}{{ teamvaultFileBase64 "k4" }}{{ end }}
+card: {{ "k5" | teamvaultCreditCardNumber }} {{ "k5" | teamvaultCreditCardHolder }}
+dynamic: {{ env "KEY" | teamvaultPassword }}
+again: {{ "k2" | teamvaultPassword }}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Reference{
+		{Kind: KindUser, Key: "k1"},
+		{Kind: KindPassword, Key: "k2"},
+		{Kind: KindPassword, Key: "k3"},
+		{Kind: KindUser, Key: "k3"},
+		{Kind: KindFile, Key: "k4"},
+		{Kind: KindCreditCard, Key: "k5"},
+	}
+	if err := AssertThat(len(references), Is(len(expected))); err != nil {
+		t.Fatal(err)
+	}
+	for i, reference := range references {
+		if err := AssertThat(reference, Is(expected[i])); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReferencesInvalidTemplate(t *testing.T) {
+	_, err := References([]byte(`{{ "k1" | teamvaultUser`))
+	if err := AssertThat(err, NotNilValue()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type concurrencyCounter struct {
+	teamvault.Connector
+	mux     sync.Mutex
+	current int
+	max     int
+	calls   int
+}
+
+func (c *concurrencyCounter) Password(ctx context.Context, key teamvault.Key) (teamvault.Password, error) {
+	c.mux.Lock()
+	c.calls++
+	c.current++
+	if c.current > c.max {
+		c.max = c.current
+	}
+	c.mux.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	c.mux.Lock()
+	c.current--
+	c.mux.Unlock()
+	return c.Connector.Password(ctx, key)
+}
+
+func TestPrefetch(t *testing.T) {
+	counter := &concurrencyCounter{Connector: connector.NewDummy()}
+	cache := connector.NewCache(counter)
+	var references []Reference
+	for _, key := range []teamvault.Key{"k1", "k2", "k3", "k4", "k5", "k6", "k7", "k8"} {
+		references = append(references, Reference{Kind: KindPassword, Key: key})
+	}
+	if err := Prefetch(context.Background(), cache, references, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(counter.calls, Is(8)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(counter.max <= 3, Is(true)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(cache.Len(), Is(8)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(cache).Parse(context.Background(), []byte(`{{ "k1" | teamvaultPassword }}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(counter.calls, Is(8)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrefetchIgnoresFailures(t *testing.T) {
+	references := []Reference{{Kind: KindPassword, Key: "missing"}}
+	if err := Prefetch(context.Background(), connector.NewMemory(), references, 2); err != nil {
+		t.Fatal(err)
+	}
+}
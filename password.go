@@ -0,0 +1,135 @@
+package teamvault
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+)
+
+const (
+	passwordLowercase = "abcdefghijklmnopqrstuvwxyz"
+	passwordUppercase = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	passwordDigits    = "0123456789"
+	passwordSymbols   = "!#$%&()*+,-./:;<=>?@[]^_{|}~"
+)
+
+// PasswordPolicy defines how GeneratePassword builds a password.
+// Every enabled character class is contained at least once.
+type PasswordPolicy struct {
+	Length    int
+	Lowercase bool
+	Uppercase bool
+	Digits    bool
+	Symbols   bool
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		Length:    32,
+		Lowercase: true,
+		Uppercase: true,
+		Digits:    true,
+		Symbols:   true,
+	}
+}
+
+func (p PasswordPolicy) classes() []string {
+	var result []string
+	if p.Lowercase {
+		result = append(result, passwordLowercase)
+	}
+	if p.Uppercase {
+		result = append(result, passwordUppercase)
+	}
+	if p.Digits {
+		result = append(result, passwordDigits)
+	}
+	if p.Symbols {
+		result = append(result, passwordSymbols)
+	}
+	return result
+}
+
+func (p PasswordPolicy) Validate() error {
+	classes := p.classes()
+	if len(classes) == 0 {
+		return fmt.Errorf("no character class enabled")
+	}
+	if p.Length < len(classes) {
+		return fmt.Errorf("length %d too short for %d character classes", p.Length, len(classes))
+	}
+	return nil
+}
+
+// GeneratePassword creates a random password from crypto/rand matching the given policy.
+func GeneratePassword(policy PasswordPolicy) (Password, error) {
+	if err := policy.Validate(); err != nil {
+		return "", err
+	}
+	classes := policy.classes()
+	var all string
+	for _, class := range classes {
+		all += class
+	}
+	result := make([]byte, policy.Length)
+	for i := range result {
+		chars := all
+		if i < len(classes) {
+			chars = classes[i]
+		}
+		c, err := randomChar(chars)
+		if err != nil {
+			return "", err
+		}
+		result[i] = c
+	}
+	// shuffle so the guaranteed characters are not always in front
+	for i := len(result) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		j := n.Int64()
+		result[i], result[j] = result[j], result[i]
+	}
+	return Password(result), nil
+}
+
+func randomChar(chars string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+	if err != nil {
+		return 0, err
+	}
+	return chars[n.Int64()], nil
+}
+
+// ReadPassword reads the password from the reader without the trailing line
+// break, e.g. from stdin, so it does not show up in the process list.
+func ReadPassword(r io.Reader) (Password, error) {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("read password failed: %v", err)
+	}
+	password := strings.TrimSuffix(strings.TrimSuffix(string(content), "\n"), "\r")
+	if password == "" {
+		return "", fmt.Errorf("password is empty")
+	}
+	return Password(password), nil
+}
+
+// ReadPasswordFile reads the password from the file, - reads stdin.
+func ReadPasswordFile(path string) (Password, error) {
+	if path == "-" {
+		return ReadPassword(os.Stdin)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open password file failed: %v", err)
+	}
+	defer file.Close()
+	return ReadPassword(file)
+}
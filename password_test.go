@@ -0,0 +1,103 @@
+package teamvault_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/bborbe/assert"
+	"github.com/bborbe/teamvault-utils"
+)
+
+func TestGeneratePassword(t *testing.T) {
+	password, err := teamvault.GeneratePassword(teamvault.DefaultPasswordPolicy())
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(len(password), Is(32)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGeneratePasswordContainsEveryClass(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		password, err := teamvault.GeneratePassword(teamvault.PasswordPolicy{
+			Length:  2,
+			Digits:  true,
+			Symbols: true,
+		})
+		if err := AssertThat(err, NilValue()); err != nil {
+			t.Fatal(err)
+		}
+		if err := AssertThat(strings.ContainsAny(password.String(), "0123456789"), Is(true)); err != nil {
+			t.Fatal(err)
+		}
+		if err := AssertThat(strings.ContainsAny(password.String(), "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"), Is(false)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGeneratePasswordInvalidPolicy(t *testing.T) {
+	var tests = []struct {
+		name   string
+		policy teamvault.PasswordPolicy
+	}{
+		{"no class", teamvault.PasswordPolicy{Length: 10}},
+		{"too short", teamvault.PasswordPolicy{Length: 1, Lowercase: true, Digits: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := teamvault.GeneratePassword(tt.policy)
+			if err := AssertThat(err, NotNilValue()); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestReadPassword(t *testing.T) {
+	for _, c := range []struct {
+		content  string
+		expected teamvault.Password
+	}{
+		{"S3CR3T", "S3CR3T"},
+		{"S3CR3T\n", "S3CR3T"},
+		{"S3CR3T\r\n", "S3CR3T"},
+		{" S3CR3T \n\n", " S3CR3T \n"},
+	} {
+		password, err := teamvault.ReadPassword(strings.NewReader(c.content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := AssertThat(password, Is(c.expected)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadPasswordEmpty(t *testing.T) {
+	for _, content := range []string{"", "\n"} {
+		if _, err := teamvault.ReadPassword(strings.NewReader(content)); err == nil {
+			t.Fatalf("error expected for %q", content)
+		}
+	}
+}
+
+func TestReadPasswordFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	if err := ioutil.WriteFile(path, []byte("S3CR3T\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	password, err := teamvault.ReadPasswordFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("S3CR3T"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := teamvault.ReadPasswordFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("error expected")
+	}
+}
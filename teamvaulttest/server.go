@@ -0,0 +1,458 @@
+// Package teamvaulttest serves the part of the Teamvault api this project
+// uses, for integration tests without a real Teamvault.
+package teamvaulttest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bborbe/teamvault-utils"
+	"github.com/golang/glog"
+)
+
+// DefaultPageSize of search results.
+const DefaultPageSize = 25
+
+// Store holds the secrets the server serves, e.g. connector.Memory or
+// connector.Fixture.
+type Store interface {
+	teamvault.Connector
+	teamvault.Writer
+	teamvault.AccessRequester
+}
+
+// Server is a http.Handler for the Teamvault api backed by the store.
+type Server struct {
+	store    Store
+	user     teamvault.User
+	password teamvault.Password
+	latency  time.Duration
+	pageSize int
+
+	mux        sync.Mutex
+	failures   map[teamvault.Key]int
+	failEvery  int
+	failStatus int
+	requests   int
+	revisions  map[teamvault.RevisionId]teamvault.Key
+}
+
+func NewServer(store Store) *Server {
+	return &Server{
+		store:     store,
+		pageSize:  DefaultPageSize,
+		failures:  make(map[teamvault.Key]int),
+		revisions: make(map[teamvault.RevisionId]teamvault.Key),
+	}
+}
+
+// WithBasicAuth rejects requests without the user and password.
+func (s *Server) WithBasicAuth(user teamvault.User, password teamvault.Password) *Server {
+	s.user = user
+	s.password = password
+	return s
+}
+
+// WithLatency delays every response.
+func (s *Server) WithLatency(latency time.Duration) *Server {
+	s.latency = latency
+	return s
+}
+
+// WithPageSize sets the number of search results per page, it panics if
+// the page size is less than 1.
+func (s *Server) WithPageSize(pageSize int) *Server {
+	if pageSize < 1 {
+		panic(fmt.Sprintf("teamvaulttest: page size must be at least 1, got %d", pageSize))
+	}
+	s.pageSize = pageSize
+	return s
+}
+
+// WithFailEvery answers every nth request with the status.
+func (s *Server) WithFailEvery(n int, status int) *Server {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.failEvery = n
+	s.failStatus = status
+	return s
+}
+
+// Fail answers all requests for the secret with the status, 0 removes
+// the failure.
+func (s *Server) Fail(key teamvault.Key, status int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if status == 0 {
+		delete(s.failures, key)
+		return
+	}
+	s.failures[key] = status
+}
+
+// Start serves on a local port until Close of the returned server.
+func (s *Server) Start() *httptest.Server {
+	return httptest.NewServer(s)
+}
+
+func (s *Server) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	glog.V(4).Infof("%s %s", req.Method, req.URL)
+	if s.latency > 0 {
+		select {
+		case <-time.After(s.latency):
+		case <-req.Context().Done():
+			return
+		}
+	}
+	if s.user != "" {
+		user, password, ok := req.BasicAuth()
+		if !ok || teamvault.User(user) != s.user || teamvault.Password(password) != s.password {
+			writeError(resp, http.StatusUnauthorized)
+			return
+		}
+	}
+	if status := s.failure(req.URL.Path); status != 0 {
+		writeError(resp, status)
+		return
+	}
+	path := strings.Trim(req.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "api/secrets" && req.Method == http.MethodGet:
+		s.search(resp, req)
+	case path == "api/secrets" && req.Method == http.MethodPost:
+		s.create(resp, req)
+	case len(parts) == 3 && parts[1] == "secrets" && req.Method == http.MethodGet:
+		s.secret(resp, req, teamvault.Key(parts[2]))
+	case len(parts) == 3 && parts[1] == "secrets" && req.Method == http.MethodPatch:
+		s.update(resp, req, teamvault.Key(parts[2]))
+	case len(parts) == 4 && parts[1] == "secrets" && parts[3] == "revisions" && req.Method == http.MethodGet:
+		s.listRevisions(resp, req, teamvault.Key(parts[2]))
+	case len(parts) == 4 && parts[1] == "secrets" && parts[3] == "access-requests" && req.Method == http.MethodPost:
+		s.requestAccess(resp, req, teamvault.Key(parts[2]))
+	case len(parts) == 4 && parts[1] == "secret-revisions" && parts[3] == "data" && req.Method == http.MethodGet:
+		s.data(resp, req, teamvault.RevisionId(parts[2]))
+	default:
+		writeError(resp, http.StatusNotFound)
+	}
+}
+
+// failure returns the status of an injected failure for the path, 0 if none.
+func (s *Server) failure(path string) int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.requests++
+	if s.failEvery > 0 && s.requests%s.failEvery == 0 {
+		return s.failStatus
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 3 {
+		return 0
+	}
+	key := teamvault.Key(parts[2])
+	if parts[1] == "secret-revisions" {
+		key = s.revisions[teamvault.RevisionId(parts[2])]
+	}
+	return s.failures[key]
+}
+
+func (s *Server) search(resp http.ResponseWriter, req *http.Request) {
+	name := req.URL.Query().Get("search")
+	page := 1
+	if value := req.URL.Query().Get("page"); value != "" {
+		var err error
+		if page, err = strconv.Atoi(value); err != nil || page < 1 {
+			writeError(resp, http.StatusBadRequest)
+			return
+		}
+	}
+	secrets, err := s.store.Search(req.Context(), teamvault.SearchOptions{Name: name})
+	if err != nil {
+		writeStoreError(resp, err)
+		return
+	}
+	response := struct {
+		Count   int                `json:"count"`
+		Next    *string            `json:"next"`
+		Results []teamvault.Secret `json:"results"`
+	}{
+		Count:   len(secrets),
+		Results: []teamvault.Secret{},
+	}
+	start := (page - 1) * s.pageSize
+	for i := start; i < len(secrets) && i < start+s.pageSize; i++ {
+		response.Results = append(response.Results, s.render(req, secrets[i]))
+	}
+	if start+s.pageSize < len(secrets) {
+		values := url.Values{}
+		values.Add("search", name)
+		values.Add("page", strconv.Itoa(page+1))
+		next := fmt.Sprintf("%s/api/secrets/?%s", baseUrl(req), values.Encode())
+		response.Next = &next
+	}
+	writeJson(resp, http.StatusOK, response)
+}
+
+func (s *Server) secret(resp http.ResponseWriter, req *http.Request, key teamvault.Key) {
+	secret, err := s.store.Secret(req.Context(), key)
+	if err != nil {
+		writeStoreError(resp, err)
+		return
+	}
+	writeJson(resp, http.StatusOK, s.render(req, *secret))
+}
+
+// render adds the urls Teamvault returns and remembers the revision.
+func (s *Server) render(req *http.Request, secret teamvault.Secret) teamvault.Secret {
+	secret.ApiUrl = teamvault.TeamvaultApiUrl(fmt.Sprintf("%s/api/secrets/%s/", baseUrl(req), secret.Key))
+	secret.WebUrl = teamvault.Url(fmt.Sprintf("%s/secrets/%s/", baseUrl(req), secret.Key))
+	if id, err := secret.CurrentRevision.Id(); err == nil {
+		s.remember(id, secret.Key)
+		secret.CurrentRevision = revisionUrl(req, id)
+	}
+	return secret
+}
+
+func (s *Server) listRevisions(resp http.ResponseWriter, req *http.Request, key teamvault.Key) {
+	revisions, err := s.store.Revisions(req.Context(), key)
+	if err != nil {
+		writeStoreError(resp, err)
+		return
+	}
+	type revision struct {
+		ApiUrl  teamvault.TeamvaultCurrentRevision `json:"api_url"`
+		Created time.Time                          `json:"created"`
+		SetBy   teamvault.User                     `json:"set_by"`
+	}
+	response := struct {
+		Results []revision `json:"results"`
+	}{
+		Results: []revision{},
+	}
+	for _, r := range revisions {
+		s.remember(r.Id, key)
+		response.Results = append(response.Results, revision{
+			ApiUrl:  revisionUrl(req, r.Id),
+			Created: r.Created,
+			SetBy:   r.SetBy,
+		})
+	}
+	writeJson(resp, http.StatusOK, response)
+}
+
+func (s *Server) data(resp http.ResponseWriter, req *http.Request, id teamvault.RevisionId) {
+	ctx := req.Context()
+	key, err := s.keyOfRevision(ctx, id)
+	if err != nil {
+		writeStoreError(resp, err)
+		return
+	}
+	secret, err := s.store.Secret(ctx, key)
+	if err != nil {
+		writeStoreError(resp, err)
+		return
+	}
+	pinned := key.WithRevision(id)
+	response := make(map[string]string)
+	switch secret.ContentType {
+	case teamvault.ContentTypeFile:
+		file, err := s.store.File(ctx, pinned)
+		if err != nil {
+			writeStoreError(resp, err)
+			return
+		}
+		response["file"] = file.String()
+	case teamvault.ContentTypeCreditCard:
+		creditCard, err := s.store.CreditCard(ctx, pinned)
+		if err != nil {
+			writeStoreError(resp, err)
+			return
+		}
+		response["holder"] = creditCard.Holder
+		response["number"] = creditCard.Number
+		response["expiration_month"] = creditCard.ExpirationMonth
+		response["expiration_year"] = creditCard.ExpirationYear
+		response["security_code"] = creditCard.SecurityCode
+	default:
+		password, err := s.store.Password(ctx, pinned)
+		if err != nil {
+			writeStoreError(resp, err)
+			return
+		}
+		response["password"] = password.String()
+	}
+	writeJson(resp, http.StatusOK, response)
+}
+
+func (s *Server) remember(id teamvault.RevisionId, key teamvault.Key) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.revisions[id] = key
+}
+
+// keyOfRevision looks up the secret of the revision, listing the revisions
+// of all secrets if it was not seen yet.
+func (s *Server) keyOfRevision(ctx context.Context, id teamvault.RevisionId) (teamvault.Key, error) {
+	s.mux.Lock()
+	key, ok := s.revisions[id]
+	s.mux.Unlock()
+	if ok {
+		return key, nil
+	}
+	secrets, err := s.store.Search(ctx, teamvault.SearchOptions{})
+	if err != nil {
+		return "", err
+	}
+	for _, secret := range secrets {
+		revisions, err := s.store.Revisions(ctx, secret.Key)
+		if err != nil {
+			return "", err
+		}
+		for _, revision := range revisions {
+			s.remember(revision.Id, secret.Key)
+			if revision.Id == id {
+				key = secret.Key
+			}
+		}
+	}
+	if key == "" {
+		return "", fmt.Errorf("revision %v %w", id, teamvault.ErrNotFound)
+	}
+	return key, nil
+}
+
+type secretWrite struct {
+	ContentType  teamvault.ContentType  `json:"content_type"`
+	Name         teamvault.Name         `json:"name"`
+	Description  teamvault.Description  `json:"description"`
+	User         teamvault.User         `json:"username"`
+	Url          teamvault.Url          `json:"url"`
+	Filename     teamvault.Filename     `json:"filename"`
+	AccessPolicy teamvault.AccessPolicy `json:"access_policy"`
+	SecretData   map[string]string      `json:"secret_data"`
+}
+
+// secretData is the reverse of the secret_data Remote sends.
+func (w secretWrite) secretData() teamvault.SecretData {
+	result := teamvault.SecretData{
+		Password: teamvault.Password(w.SecretData["password"]),
+		File:     teamvault.File(w.SecretData["file"]),
+	}
+	if w.SecretData["number"] != "" {
+		result.CreditCard = &teamvault.CreditCard{
+			Holder:          w.SecretData["holder"],
+			Number:          w.SecretData["number"],
+			ExpirationMonth: w.SecretData["expiration_month"],
+			ExpirationYear:  w.SecretData["expiration_year"],
+			SecurityCode:    w.SecretData["security_code"],
+		}
+	}
+	return result
+}
+
+func (s *Server) create(resp http.ResponseWriter, req *http.Request) {
+	var request secretWrite
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		writeError(resp, http.StatusBadRequest)
+		return
+	}
+	data := request.secretData()
+	key, err := s.store.Create(req.Context(), teamvault.NewSecret{
+		ContentType:  request.ContentType,
+		Name:         request.Name,
+		Description:  request.Description,
+		User:         request.User,
+		Url:          request.Url,
+		Filename:     request.Filename,
+		AccessPolicy: request.AccessPolicy,
+		Password:     data.Password,
+		File:         data.File,
+		CreditCard:   data.CreditCard,
+	})
+	if err != nil {
+		writeError(resp, http.StatusBadRequest)
+		return
+	}
+	writeJson(resp, http.StatusCreated, map[string]string{
+		"api_url": fmt.Sprintf("%s/api/secrets/%s/", baseUrl(req), key),
+	})
+}
+
+func (s *Server) update(resp http.ResponseWriter, req *http.Request, key teamvault.Key) {
+	var request secretWrite
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		writeError(resp, http.StatusBadRequest)
+		return
+	}
+	revision, err := s.store.Update(req.Context(), key, request.secretData())
+	if err != nil {
+		writeStoreError(resp, err)
+		return
+	}
+	id, err := revision.Id()
+	if err != nil {
+		writeError(resp, http.StatusInternalServerError)
+		return
+	}
+	s.remember(id, key)
+	writeJson(resp, http.StatusOK, map[string]teamvault.TeamvaultCurrentRevision{
+		"current_revision": revisionUrl(req, id),
+	})
+}
+
+func (s *Server) requestAccess(resp http.ResponseWriter, req *http.Request, key teamvault.Key) {
+	var request struct {
+		Reason teamvault.Reason `json:"reason"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil || request.Reason == "" {
+		writeError(resp, http.StatusBadRequest)
+		return
+	}
+	if err := s.store.RequestAccess(req.Context(), key, request.Reason); err != nil {
+		writeStoreError(resp, err)
+		return
+	}
+	writeJson(resp, http.StatusCreated, map[string]string{})
+}
+
+func baseUrl(req *http.Request) string {
+	return fmt.Sprintf("http://%s", req.Host)
+}
+
+func revisionUrl(req *http.Request, id teamvault.RevisionId) teamvault.TeamvaultCurrentRevision {
+	return teamvault.TeamvaultCurrentRevision(fmt.Sprintf("%s/api/secret-revisions/%s/", baseUrl(req), id))
+}
+
+// writeStoreError answers with the status Teamvault uses for the error.
+func writeStoreError(resp http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, teamvault.ErrNotFound):
+		writeError(resp, http.StatusNotFound)
+	case errors.Is(err, teamvault.ErrAccessRequestRequired), errors.Is(err, teamvault.ErrForbidden):
+		writeError(resp, http.StatusForbidden)
+	default:
+		glog.V(2).Infof("store failed: %v", err)
+		writeError(resp, http.StatusBadRequest)
+	}
+}
+
+func writeError(resp http.ResponseWriter, status int) {
+	writeJson(resp, status, map[string]string{"detail": http.StatusText(status)})
+}
+
+func writeJson(resp http.ResponseWriter, status int, value interface{}) {
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(status)
+	if err := json.NewEncoder(resp).Encode(value); err != nil {
+		glog.V(2).Infof("write response failed: %v", err)
+	}
+}
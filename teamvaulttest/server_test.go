@@ -0,0 +1,260 @@
+package teamvaulttest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/bborbe/assert"
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/connector"
+	"github.com/bborbe/teamvault-utils/teamvaulttest"
+)
+
+const fixture = `{
+	"vLVLbm": {"name": "Database", "username": "admin", "password": "S3CR3T", "url": "https://db.example.com", "access_policy": "everyone"},
+	"Xb2kqp": {"name": "TLS Key", "filename": "tls.key", "file_content": "KEY", "access_policy": "everyone"},
+	"r8Kl0q": {"name": "Card", "access_policy": "everyone", "credit_card": {"holder": "Jane", "number": "4111111111111111", "expiration_month": "12", "expiration_year": "2030", "security_code": "123"}}
+}`
+
+func start(t *testing.T) (*teamvaulttest.Server, *connector.Fixture, *connector.Remote) {
+	store, err := connector.ParseFixture([]byte(fixture), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := teamvaulttest.NewServer(store).WithBasicAuth("user", "pass")
+	httpServer := server.Start()
+	t.Cleanup(httpServer.Close)
+	remote := connector.NewRemote(httpServer.Client().Do, teamvault.Url(httpServer.URL), "user", "pass")
+	return server, store, remote
+}
+
+func TestRemoteReadsSecrets(t *testing.T) {
+	ctx := context.Background()
+	_, _, remote := start(t)
+	password, err := remote.Password(ctx, "vLVLbm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("S3CR3T"))); err != nil {
+		t.Fatal(err)
+	}
+	user, err := remote.User(ctx, "vLVLbm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(user, Is(teamvault.User("admin"))); err != nil {
+		t.Fatal(err)
+	}
+	file, err := remote.File(ctx, "Xb2kqp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := file.Content()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(string(content), Is("KEY")); err != nil {
+		t.Fatal(err)
+	}
+	creditCard, err := remote.CreditCard(ctx, "r8Kl0q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(creditCard.Number, Is("4111111111111111")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := remote.Password(ctx, "unknown"); !errors.Is(err, teamvault.ErrNotFound) {
+		t.Fatalf("not found expected, got %v", err)
+	}
+}
+
+func TestRemoteUpdatesAndPinsRevisions(t *testing.T) {
+	ctx := context.Background()
+	_, _, remote := start(t)
+	if _, err := remote.Update(ctx, "vLVLbm", teamvault.SecretData{Password: "N3W"}); err != nil {
+		t.Fatal(err)
+	}
+	password, err := remote.Password(ctx, "vLVLbm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("N3W"))); err != nil {
+		t.Fatal(err)
+	}
+	revisions, err := remote.Revisions(ctx, "vLVLbm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(len(revisions), Is(2)); err != nil {
+		t.Fatal(err)
+	}
+	_, _, fresh := start(t)
+	password, err = fresh.Password(ctx, teamvault.Key("vLVLbm").WithRevision("vLVLbmr1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("S3CR3T"))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRemoteCreditCardOfOtherContentType(t *testing.T) {
+	_, _, remote := start(t)
+	if _, err := remote.CreditCard(context.Background(), "vLVLbm"); !errors.Is(err, teamvault.ErrNotFound) {
+		t.Fatalf("not found expected, got %v", err)
+	}
+}
+
+func TestRemoteSeesRotationAfterTTL(t *testing.T) {
+	ctx := context.Background()
+	_, store, remote := start(t)
+	remote = remote.WithTTL(10 * time.Millisecond)
+	if _, err := remote.Password(ctx, "vLVLbm"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Update(ctx, "vLVLbm", teamvault.SecretData{Password: "N3W"}); err != nil {
+		t.Fatal(err)
+	}
+	password, err := remote.Password(ctx, "vLVLbm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("S3CR3T"))); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	password, err = remote.Password(ctx, "vLVLbm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("N3W"))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRemoteCreate(t *testing.T) {
+	ctx := context.Background()
+	_, _, remote := start(t)
+	key, err := remote.Create(ctx, teamvault.NewSecret{
+		ContentType: teamvault.ContentTypePassword,
+		Name:        "New",
+		Password:    "P4SS",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, err := remote.Password(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(password, Is(teamvault.Password("P4SS"))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRemoteSearchPagination(t *testing.T) {
+	store, err := connector.ParseFixture([]byte(fixture), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := teamvaulttest.NewServer(store).WithPageSize(1).Start()
+	defer httpServer.Close()
+	remote := connector.NewRemote(httpServer.Client().Do, teamvault.Url(httpServer.URL), "", "")
+	secrets, err := remote.Search(context.Background(), teamvault.SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(len(secrets), Is(3)); err != nil {
+		t.Fatal(err)
+	}
+	secrets, err = remote.Search(context.Background(), teamvault.SearchOptions{Name: "tls"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(len(secrets), Is(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(secrets[0].Key, Is(teamvault.Key("Xb2kqp"))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithPageSizeRejectsEmptyPages(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("panic expected")
+		}
+	}()
+	teamvaulttest.NewServer(connector.NewMemory()).WithPageSize(0)
+}
+
+func TestRemoteAccessRequest(t *testing.T) {
+	ctx := context.Background()
+	store, err := connector.ParseFixture([]byte(`{"vLVLbm": {"password": "S3CR3T", "access_policy": "request"}}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Restrict("vLVLbm"); err != nil {
+		t.Fatal(err)
+	}
+	httpServer := teamvaulttest.NewServer(store).Start()
+	defer httpServer.Close()
+	remote := connector.NewRemote(httpServer.Client().Do, teamvault.Url(httpServer.URL), "", "")
+	if _, err := remote.Password(ctx, "vLVLbm"); !errors.Is(err, teamvault.ErrAccessRequestRequired) {
+		t.Fatalf("access request required expected, got %v", err)
+	}
+	if err := remote.RequestAccess(ctx, "vLVLbm", "deploy"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(len(store.AccessRequests("vLVLbm")), Is(1)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	store, err := connector.ParseFixture([]byte(fixture), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := teamvaulttest.NewServer(store).WithBasicAuth("user", "pass").Start()
+	defer httpServer.Close()
+	remote := connector.NewRemote(httpServer.Client().Do, teamvault.Url(httpServer.URL), "user", "wrong")
+	if _, err := remote.Password(context.Background(), "vLVLbm"); !errors.Is(err, teamvault.ErrUnauthorized) {
+		t.Fatalf("unauthorized expected, got %v", err)
+	}
+}
+
+func TestInjectedFailures(t *testing.T) {
+	ctx := context.Background()
+	server, _, remote := start(t)
+	server.Fail("vLVLbm", http.StatusServiceUnavailable)
+	if _, err := remote.User(ctx, "vLVLbm"); !errors.Is(err, teamvault.ErrServerUnavailable) {
+		t.Fatalf("server unavailable expected, got %v", err)
+	}
+	server.Fail("vLVLbm", 0)
+	if _, err := remote.User(ctx, "vLVLbm"); err != nil {
+		t.Fatal(err)
+	}
+	server.WithFailEvery(1, http.StatusInternalServerError)
+	if _, err := remote.User(ctx, "Xb2kqp"); !errors.Is(err, teamvault.ErrServerUnavailable) {
+		t.Fatalf("server unavailable expected, got %v", err)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	store, err := connector.ParseFixture([]byte(fixture), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := teamvaulttest.NewServer(store).WithLatency(time.Second).Start()
+	defer httpServer.Close()
+	remote := connector.NewRemote(httpServer.Client().Do, teamvault.Url(httpServer.URL), "", "")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := remote.User(ctx, "vLVLbm"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("deadline exceeded expected, got %v", err)
+	}
+}
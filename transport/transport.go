@@ -0,0 +1,144 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	io_util "github.com/bborbe/io/util"
+	"github.com/bborbe/teamvault-utils"
+	"github.com/golang/glog"
+)
+
+const (
+	// DefaultRequestTimeout is the timeout of the commands before it became configurable.
+	DefaultRequestTimeout      = 5 * time.Second
+	DefaultDialTimeout         = 5 * time.Second
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+	DefaultIdleConnTimeout     = 90 * time.Second
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 10
+)
+
+// NewClient builds the http client for the Teamvault api as configured.
+// Server certificates are verified against the system roots and the
+// configured CA bundle.
+func NewClient(config *teamvault.TeamvaultConfig) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := newProxy(config.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	glog.V(4).Infof("build http client")
+	return &http.Client{
+		Timeout: RequestTimeout(config),
+		Transport: &http.Transport{
+			Proxy: proxy,
+			DialContext: (&net.Dialer{
+				Timeout:   orDefault(config.DialTimeout, DefaultDialTimeout),
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSClientConfig:     tlsConfig,
+			TLSHandshakeTimeout: orDefault(config.TLSHandshakeTimeout, DefaultTLSHandshakeTimeout),
+			IdleConnTimeout:     orDefault(config.IdleConnTimeout, DefaultIdleConnTimeout),
+			MaxIdleConns:        orDefaultInt(config.MaxIdleConns, DefaultMaxIdleConns),
+			MaxIdleConnsPerHost: orDefaultInt(config.MaxIdleConnsPerHost, DefaultMaxIdleConnsPerHost),
+			MaxConnsPerHost:     config.MaxConnsPerHost,
+		},
+	}, nil
+}
+
+// RequestTimeout returns the configured request timeout or
+// DefaultRequestTimeout.
+func RequestTimeout(config *teamvault.TeamvaultConfig) time.Duration {
+	return orDefault(config.RequestTimeout, DefaultRequestTimeout)
+}
+
+func newTLSConfig(config *teamvault.TeamvaultConfig) (*tls.Config, error) {
+	result := &tls.Config{
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if config.InsecureSkipVerify {
+		glog.Warningf("verification of teamvault server certificate is disabled")
+	}
+	if config.CaFile != "" {
+		content, err := readFile(config.CaFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca_file failed: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			glog.V(2).Infof("load system cert pool failed: %v", err)
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("ca_file %s contains no certificate", config.CaFile)
+		}
+		result.RootCAs = pool
+	}
+	if config.CertFile != "" || config.KeyFile != "" {
+		if config.CertFile == "" || config.KeyFile == "" {
+			return nil, fmt.Errorf("cert_file and key_file must be set together")
+		}
+		certPEM, err := readFile(config.CertFile)
+		if err != nil {
+			return nil, fmt.Errorf("read cert_file failed: %v", err)
+		}
+		keyPEM, err := readFile(config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read key_file failed: %v", err)
+		}
+		certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate failed: %v", err)
+		}
+		result.Certificates = []tls.Certificate{certificate}
+	}
+	return result, nil
+}
+
+// newProxy returns no proxy for an empty value, the proxy of the
+// environment for "environment" and the given proxy url otherwise.
+func newProxy(value string) (func(*http.Request) (*url.URL, error), error) {
+	switch value {
+	case "":
+		return nil, nil
+	case "environment":
+		return http.ProxyFromEnvironment, nil
+	}
+	proxyUrl, err := url.Parse(value)
+	if err != nil || proxyUrl.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %s", value)
+	}
+	return http.ProxyURL(proxyUrl), nil
+}
+
+func readFile(path string) ([]byte, error) {
+	path, err := io_util.NormalizePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(path)
+}
+
+func orDefault(value teamvault.Duration, defaultValue time.Duration) time.Duration {
+	if value > 0 {
+		return value.Duration()
+	}
+	return defaultValue
+}
+
+func orDefaultInt(value int, defaultValue int) int {
+	if value > 0 {
+		return value
+	}
+	return defaultValue
+}
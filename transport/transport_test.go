@@ -0,0 +1,159 @@
+package transport_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/bborbe/assert"
+	"github.com/bborbe/teamvault-utils"
+	"github.com/bborbe/teamvault-utils/transport"
+)
+
+func writeFile(t *testing.T, dir string, name string, block *pem.Block) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newServer(t *testing.T, clientAuth tls.ClientAuthType) (*httptest.Server, string) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: clientAuth}
+	server.StartTLS()
+	dir, err := ioutil.TempDir("", "transport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "ca.pem", &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	return server, dir
+}
+
+func get(config *teamvault.TeamvaultConfig, url string) error {
+	client, err := transport.NewClient(config)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func TestNewClientVerifiesServer(t *testing.T) {
+	server, dir := newServer(t, tls.NoClientCert)
+	defer server.Close()
+	defer os.RemoveAll(dir)
+	err := get(&teamvault.TeamvaultConfig{}, server.URL)
+	if err := AssertThat(err, NotNilValue()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewClientCaFile(t *testing.T) {
+	server, dir := newServer(t, tls.NoClientCert)
+	defer server.Close()
+	defer os.RemoveAll(dir)
+	err := get(&teamvault.TeamvaultConfig{CaFile: filepath.Join(dir, "ca.pem")}, server.URL)
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewClientInsecureSkipVerify(t *testing.T) {
+	server, dir := newServer(t, tls.NoClientCert)
+	defer server.Close()
+	defer os.RemoveAll(dir)
+	err := get(&teamvault.TeamvaultConfig{InsecureSkipVerify: true}, server.URL)
+	if err := AssertThat(err, NilValue()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewClientCertificate(t *testing.T) {
+	server, dir := newServer(t, tls.RequireAnyClientCert)
+	defer server.Close()
+	defer os.RemoveAll(dir)
+	config := &teamvault.TeamvaultConfig{CaFile: filepath.Join(dir, "ca.pem")}
+	if err := AssertThat(get(config, server.URL), NotNilValue()); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.CertFile = writeFile(t, dir, "cert.pem", &pem.Block{Type: "CERTIFICATE", Bytes: certificate})
+	config.KeyFile = writeFile(t, dir, "key.pem", &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes})
+	if err := AssertThat(get(config, server.URL), NilValue()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewClientInvalidConfig(t *testing.T) {
+	var tests = []struct {
+		name   string
+		config teamvault.TeamvaultConfig
+	}{
+		{"missing ca file", teamvault.TeamvaultConfig{CaFile: "/nonexistent/ca.pem"}},
+		{"cert without key", teamvault.TeamvaultConfig{CertFile: "/nonexistent/cert.pem"}},
+		{"invalid proxy", teamvault.TeamvaultConfig{Proxy: "::"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := transport.NewClient(&tt.config)
+			if err := AssertThat(err, NotNilValue()); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestNewClientRequestTimeout(t *testing.T) {
+	client, err := transport.NewClient(&teamvault.TeamvaultConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(client.Timeout, Is(5*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	client, err = transport.NewClient(&teamvault.TeamvaultConfig{RequestTimeout: teamvault.Duration(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertThat(client.Timeout, Is(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+}